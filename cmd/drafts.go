@@ -0,0 +1,117 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+
+	common "github.com/404wolf/valfs/common"
+	vals "github.com/404wolf/valfs/valfs/vals"
+	"github.com/spf13/cobra"
+)
+
+var draftsDir string
+
+var publishCmd = &cobra.Command{
+	Use:   "publish [val...]",
+	Short: "Push local drafts of vals to Val Town",
+	Long:  "Push local drafts of vals to Val Town. Vals can be given by id, name or file name, and all drafts are published if none are given.",
+	Run: func(cmd *cobra.Command, args []string) {
+		forEachDraft(args, func(ctx context.Context, drafts *vals.DraftStore, val vals.Val) bool {
+			if err := drafts.PublishDraft(ctx, val); err != nil {
+				fmt.Fprintf(os.Stderr, "Failed to publish draft of %s. Error: %v\n", val.GetName(), err)
+				return false
+			}
+			fmt.Printf("Published draft of %s\n", val.GetName())
+			return true
+		})
+	},
+}
+
+var discardCmd = &cobra.Command{
+	Use:   "discard [val...]",
+	Short: "Drop local drafts of vals",
+	Long:  "Drop local drafts of vals without pushing them. Vals can be given by id, name or file name, and all drafts are dropped if none are given.",
+	Run: func(cmd *cobra.Command, args []string) {
+		forEachDraft(args, func(ctx context.Context, drafts *vals.DraftStore, val vals.Val) bool {
+			if err := drafts.Delete(val.GetId()); err != nil {
+				fmt.Fprintf(os.Stderr, "Failed to discard draft of %s. Error: %v\n", val.GetName(), err)
+				return false
+			}
+			fmt.Printf("Discarded draft of %s\n", val.GetName())
+			return true
+		})
+	},
+}
+
+// forEachDraft calls do for every val with a draft that matches one of the
+// given ids, names or file names, or for all of them if none are given. do
+// returns whether it succeeded, and the command exits with an error once every
+// draft was handled if any of them failed.
+func forEachDraft(
+	args []string,
+	do func(ctx context.Context, drafts *vals.DraftStore, val vals.Val) bool,
+) {
+	apiKey := getAPIKey()
+	if apiKey == "" {
+		fmt.Fprintf(os.Stderr, "VAL_TOWN_API_KEY not found. Please set it in environment or .env file\n")
+		os.Exit(1)
+	}
+
+	ctx := context.Background()
+	client, err := common.NewClient(apiKey, ctx, false, common.ValfsConfig{APIKey: apiKey})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to create client. Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	drafts := vals.NewDraftStore(draftsDir)
+	valIds, err := drafts.List()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to list drafts. Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	failed := false
+	for _, valId := range valIds {
+		val := vals.ValDirValOf(client.APIClient, valId)
+		if err := val.Load(ctx); err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to load val %s. Error: %v\n", valId, err)
+			failed = true
+			continue
+		}
+
+		if len(args) == 0 || matchesVal(args, val) {
+			if !do(ctx, drafts, val) {
+				failed = true
+			}
+		}
+	}
+
+	if failed {
+		os.Exit(1)
+	}
+}
+
+// matchesVal returns whether any of the args refers to the val, by id, name,
+// or the name or path of its file
+func matchesVal(args []string, val vals.Val) bool {
+	filenames := []string{}
+	for _, layout := range vals.Layouts {
+		filenames = append(filenames, vals.ConstructLayoutFilename(layout, val.GetName(), val.GetValType()))
+	}
+	return slices.ContainsFunc(args, func(arg string) bool {
+		return arg == val.GetId() ||
+			arg == val.GetName() ||
+			slices.Contains(filenames, filepath.Base(arg))
+	})
+}
+
+func DraftsInit() {
+	for _, draftsCmd := range []*cobra.Command{publishCmd, discardCmd} {
+		draftsCmd.Flags().StringVar(&draftsDir, "drafts-dir", vals.DefaultDraftsDir(), "where local drafts of vals are stored")
+		rootCmd.AddCommand(draftsCmd)
+	}
+}
@@ -4,11 +4,13 @@ import (
 	"context"
 	"fmt"
 	"os"
+	"slices"
+	"strings"
 
 	common "github.com/404wolf/valfs/common"
 	valfs "github.com/404wolf/valfs/valfs"
+	vals "github.com/404wolf/valfs/valfs/vals"
 	"github.com/spf13/cobra"
-	"github.com/spf13/viper"
 )
 
 var valfsConfig = &common.ValfsConfig{}
@@ -20,33 +22,28 @@ var mountCmd = &cobra.Command{
 	Run: func(cmd *cobra.Command, args []string) {
 		valfsConfig.MountPoint = args[0]
 
-		// First check direct environment variable
-		apiKey := os.Getenv("VAL_TOWN_API_KEY")
-
-		// If not found in environment, try .env file
+		apiKey := getAPIKey()
 		if apiKey == "" {
-			// Setup Viper for .env
-			viper.SetConfigFile(".env")
-			viper.SetConfigType("env")
-			viper.AutomaticEnv()
+			fmt.Printf("VAL_TOWN_API_KEY not found. Please set it in environment or .env file")
+		}
+		valfsConfig.APIKey = apiKey
 
-			// Read config file
-			if err := viper.ReadInConfig(); err != nil {
-				// It's okay if there's no config file
-				if _, ok := err.(viper.ConfigFileNotFoundError); !ok {
-					fmt.Printf("Error reading config file: %v", err)
-				}
+		// Make sure all the requested frontmatter fields exist
+		for _, field := range valfsConfig.FrontmatterFields {
+			if !slices.Contains(vals.FrontmatterFields, field) {
+				fmt.Fprintf(os.Stderr, "Unknown frontmatter field %s. Valid fields are: %s\n", field, strings.Join(vals.FrontmatterFields, ", "))
+				os.Exit(1)
 			}
-
-			// Get API key from Viper
-			apiKey = viper.GetString("VAL_TOWN_API_KEY")
 		}
 
-		// Final make sure API key is set
-		if apiKey == "" {
-			fmt.Printf("VAL_TOWN_API_KEY not found. Please set it in environment or .env file")
+		if !slices.Contains(vals.Layouts, valfsConfig.Layout) {
+			fmt.Fprintf(os.Stderr, "Unknown layout %s. Valid layouts are: %s\n", valfsConfig.Layout, strings.Join(vals.Layouts, ", "))
+			os.Exit(1)
+		}
+		if valfsConfig.Layout == vals.LayoutByType && valfsConfig.Folders {
+			fmt.Fprintf(os.Stderr, "Folders can't be used with the %s layout\n", vals.LayoutByType)
+			os.Exit(1)
 		}
-		valfsConfig.APIKey = apiKey
 
 		// Create a new val town client
 		client, err := common.NewClient(
@@ -79,9 +76,25 @@ func ValfsInit() {
 	mountCmd.Flags().IntVar(&valfsConfig.AutoRefreshInterval, "refresh-interval", 5, "how often to poll val town website for changes (in seconds)")
 	mountCmd.Flags().BoolVar(&valfsConfig.EnableValsDirectory, "vals-directory", true, "add a directory for your vals")
 	mountCmd.Flags().BoolVar(&valfsConfig.EnableBlobsDirectory, "blobs-directory", true, "add a directory for your blobs")
+	mountCmd.Flags().BoolVar(&valfsConfig.EnableByIdDirectory, "by-id-directory", true, "add a directory where vals can be looked up by id")
+	mountCmd.Flags().BoolVar(&valfsConfig.EnableUsersDirectory, "users-directory", true, "add a directory with other users' public vals")
+	mountCmd.Flags().BoolVar(&valfsConfig.EnableSearchDirectory, "search-directory", true, "add a directory where looking up a query searches all vals")
+	mountCmd.Flags().BoolVar(&valfsConfig.EnableLikedDirectory, "liked-directory", true, "add a directory with the vals you have liked")
+	mountCmd.Flags().BoolVar(&valfsConfig.EnableSqliteDirectory, "sqlite-directory", false, "add a directory with the tables of your SQLite database")
+	mountCmd.Flags().BoolVar(&valfsConfig.EnableOutboxDirectory, "outbox-directory", false, "add a directory where writing an email file sends it to you")
+	mountCmd.Flags().BoolVar(&valfsConfig.EnableMeFile, "me-file", true, "add a me.json file with your profile and counts of your vals")
+	mountCmd.Flags().BoolVar(&valfsConfig.EnableViews, "views", false, "add by-privacy and by-type directories with links to your vals")
 	mountCmd.Flags().BoolVar(&valfsConfig.GoFuseDebug, "fuse-debug", false, "enable go fuse's debug mode")
 	mountCmd.Flags().BoolVar(&valfsConfig.StaticMeta, "static-writes", false, "ensure val file metadata doesn't change on writes")
 	mountCmd.Flags().BoolVar(&valfsConfig.ExecutableVals, "executable-vals", true, "whether vals have the executable bit, so you can \"run\" them")
+	mountCmd.Flags().StringSliceVar(&valfsConfig.FrontmatterFields, "frontmatter-fields", vals.FrontmatterFields, "which fields to show in the frontmatter of val files")
+	mountCmd.Flags().IntVar(&valfsConfig.WriteDebounce, "write-debounce", 0, "how long to wait for more writes before creating a new version (in milliseconds)")
+	mountCmd.Flags().BoolVar(&valfsConfig.Drafts, "drafts", false, "only save writes to local drafts, which are pushed with \"valfs publish\"")
+	mountCmd.Flags().StringVar(&valfsConfig.DraftsDir, "drafts-dir", vals.DefaultDraftsDir(), "where to store local drafts of vals")
+	mountCmd.Flags().BoolVar(&valfsConfig.ReadmeFiles, "readme-files", false, "expose val readmes as separate name.README.md files")
+	mountCmd.Flags().BoolVar(&valfsConfig.Folders, "folders", false, "allow grouping vals into folders, which are only stored locally")
+	mountCmd.Flags().StringVar(&valfsConfig.FoldersFile, "folders-file", vals.DefaultFoldersFile(), "where to store the folders that vals are grouped into")
+	mountCmd.Flags().StringVar(&valfsConfig.Layout, "layout", vals.LayoutFlat, "how to lay out val files, either \"flat\" (name.H.tsx) or \"by-type\" (http/name.tsx)")
 
 	rootCmd.AddCommand(mountCmd)
 }
@@ -49,6 +49,8 @@ func InitRoot() {
 	rootCmd.PersistentFlags().BoolVar(&silent, "silent", false, "disable stdout logging")
 
 	ValfsInit()
+	DraftsInit()
+	SqliteInit()
 }
 
 func Execute() error {
@@ -0,0 +1,105 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	common "github.com/404wolf/valfs/common"
+	sqlite "github.com/404wolf/valfs/valfs/sqlite"
+	"github.com/spf13/cobra"
+)
+
+var sqliteCmd = &cobra.Command{
+	Use:   "sqlite",
+	Short: "Back up, restore and migrate your Val Town SQLite database",
+}
+
+var sqliteDumpCmd = &cobra.Command{
+	Use:   "dump",
+	Short: "Print SQL that recreates your SQLite database",
+	Long:  "Print SQL that recreates your SQLite database, with the schema and the rows of every table, for example valfs sqlite dump > backup.sql",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		// The dump goes to stdout, so logs can't
+		common.Logger = common.SetupLogger(logFile, logLevel, true)
+
+		client, err := newSqliteClient()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to create client. Error: %v\n", err)
+			os.Exit(1)
+		}
+
+		if err := sqlite.Dump(context.Background(), client.APIClient, os.Stdout); err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to dump database. Error: %v\n", err)
+			os.Exit(1)
+		}
+	},
+}
+
+var sqliteRestoreCmd = &cobra.Command{
+	Use:   "restore <file>",
+	Short: "Run the SQL in a file, like a dump, against your SQLite database",
+	Long:  "Run the SQL in a file, like one made by valfs sqlite dump, against your SQLite database. The statements run in a single transaction, so nothing is changed if one fails.",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		sql, err := os.ReadFile(args[0])
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to read %s. Error: %v\n", args[0], err)
+			os.Exit(1)
+		}
+
+		client, err := newSqliteClient()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to create client. Error: %v\n", err)
+			os.Exit(1)
+		}
+
+		count, err := sqlite.Restore(context.Background(), client.APIClient, string(sql))
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to restore %s, nothing was changed. Error: %v\n", args[0], err)
+			os.Exit(1)
+		}
+		fmt.Printf("Ran %d statements from %s\n", count, args[0])
+	},
+}
+
+var sqliteMigrateCmd = &cobra.Command{
+	Use:   "migrate <dir>",
+	Short: "Apply numbered SQL migrations that haven't been applied yet",
+	Long:  "Apply the numbered SQL files in a directory, like 001_create_users.sql, that haven't been applied yet, in order. Applied versions are recorded in the " + sqlite.MigrationsTable + " table.",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		client, err := newSqliteClient()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to create client. Error: %v\n", err)
+			os.Exit(1)
+		}
+
+		applied, err := sqlite.Migrate(context.Background(), client.APIClient, args[0])
+		for _, migration := range applied {
+			fmt.Printf("Applied %s\n", migration.Name)
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to migrate. Error: %v\n", err)
+			os.Exit(1)
+		}
+		if len(applied) == 0 {
+			fmt.Println("Already up to date")
+		}
+	},
+}
+
+// newSqliteClient creates a client with the API key from the environment
+func newSqliteClient() (*common.Client, error) {
+	apiKey := getAPIKey()
+	if apiKey == "" {
+		return nil, fmt.Errorf("VAL_TOWN_API_KEY not found. Please set it in environment or .env file")
+	}
+	return common.NewClient(apiKey, context.Background(), false, common.ValfsConfig{APIKey: apiKey})
+}
+
+func SqliteInit() {
+	sqliteCmd.AddCommand(sqliteDumpCmd, sqliteRestoreCmd, sqliteMigrateCmd)
+	rootCmd.AddCommand(sqliteCmd)
+}
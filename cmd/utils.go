@@ -2,6 +2,10 @@ package cmd
 
 import (
 	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/spf13/viper"
 )
 
 func PrettyPrint(v interface{}) string {
@@ -11,3 +15,31 @@ func PrettyPrint(v interface{}) string {
 	}
 	return string(b)
 }
+
+// getAPIKey gets the val town API key from the environment, or from a .env
+// file if it isn't set there. Returns an empty string if neither has it.
+func getAPIKey() string {
+	// First check direct environment variable
+	apiKey := os.Getenv("VAL_TOWN_API_KEY")
+
+	// If not found in environment, try .env file
+	if apiKey == "" {
+		// Setup Viper for .env
+		viper.SetConfigFile(".env")
+		viper.SetConfigType("env")
+		viper.AutomaticEnv()
+
+		// Read config file
+		if err := viper.ReadInConfig(); err != nil {
+			// It's okay if there's no config file
+			if _, ok := err.(viper.ConfigFileNotFoundError); !ok {
+				fmt.Printf("Error reading config file: %v", err)
+			}
+		}
+
+		// Get API key from Viper
+		apiKey = viper.GetString("VAL_TOWN_API_KEY")
+	}
+
+	return apiKey
+}
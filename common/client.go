@@ -3,6 +3,7 @@ package common
 import (
 	"context"
 	"math/rand/v2"
+	"net/http"
 	"time"
 
 	"github.com/404wolf/valgo"
@@ -15,6 +16,7 @@ type Client struct {
 	Id        uint64
 	Started   time.Time
 	User      valgo.User
+	Profile   map[string]any // Everything val town says about the user, like their tier
 }
 
 func NewClient(
@@ -44,6 +46,13 @@ func NewClient(
 		userResp.Links,
 	)
 
+	// valgo's user doesn't have every field of the profile, like the tier
+	profile := make(map[string]any)
+	err = apiClient.RawJSONRequest(ctx, http.MethodGet, "/v1/me", nil, &profile)
+	if err != nil {
+		return nil, err
+	}
+
 	client := &Client{
 		APIClient: apiClient,
 		APIKey:    apiKey,
@@ -51,6 +60,7 @@ func NewClient(
 		Id:        rand.Uint64(),
 		Started:   time.Now(),
 		User:      *user,
+		Profile:   profile,
 	}
 
 	return client, nil
@@ -28,6 +28,27 @@ type ValfsConfig struct {
 	// Add a directory for your blob
 	EnableBlobsDirectory bool
 
+	// Add a directory where any val can be looked up by its id
+	EnableByIdDirectory bool
+
+	// Add a directory with the public vals of other users
+	EnableUsersDirectory bool
+
+	// Add a directory where looking up a query searches all vals
+	EnableSearchDirectory bool
+
+	// Add a directory with the vals that you have liked
+	EnableLikedDirectory bool
+
+	// Add a directory with the tables of your SQLite database
+	EnableSqliteDirectory bool
+
+	// Add a directory where writing an email file sends it to you
+	EnableOutboxDirectory bool
+
+	// Add a me.json file with your profile and counts of your vals
+	EnableMeFile bool
+
 	// Whether to enable go fuse's debug mode
 	GoFuseDebug bool
 
@@ -37,4 +58,37 @@ type ValfsConfig struct {
 
 	// Whether to have vals be executable so that you can "run" them
 	ExecutableVals bool
+
+	// Whether to expose each val's readme as a separate name.README.md file
+	// instead of as a field in the frontmatter
+	ReadmeFiles bool
+
+	// Which fields to show in the frontmatter at the top of val files
+	FrontmatterFields []string
+
+	// How long to wait for more writes to a val before pushing it to val town,
+	// in milliseconds, so that a burst of saves creates only one version. Zero
+	// pushes every write right away.
+	WriteDebounce int
+
+	// Whether writes to vals only go to local drafts, which are pushed to val
+	// town with the publish command
+	Drafts bool
+
+	// Where local drafts of vals are stored
+	DraftsDir string
+
+	// Whether vals can be grouped into folders, which only exist locally
+	Folders bool
+
+	// Where the folders that vals are grouped into are stored
+	FoldersFile string
+
+	// How val files are laid out in the vals directory, either "flat" for
+	// name.H.tsx files or "by-type" for http/name.tsx files
+	Layout string
+
+	// Whether to add by-privacy and by-type directories with symlinks to the
+	// val files, grouped by privacy and type
+	EnableViews bool
 }
@@ -0,0 +1,24 @@
+package common
+
+import (
+	"context"
+	"net/http"
+)
+
+// Email is an email to send to the owner of the val town account
+type Email struct {
+	Subject string
+	Text    string
+	HTML    string
+}
+
+// SendEmail sends an email to the owner of the val town account
+func (c *APIClient) SendEmail(ctx context.Context, email Email) error {
+	request := struct {
+		Subject string `json:"subject"`
+		Text    string `json:"text,omitempty"`
+		HTML    string `json:"html,omitempty"`
+	}{email.Subject, email.Text, email.HTML}
+
+	return c.RawJSONRequest(ctx, http.MethodPost, "/v1/email", request, nil)
+}
@@ -0,0 +1,102 @@
+package common
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"regexp"
+	"strconv"
+	"syscall"
+)
+
+// APIError is an error from a request to the val town API, along with the
+// HTTP status code of the response
+type APIError struct {
+	StatusCode int
+	Err        error
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("val town API error (status %d): %v", e.StatusCode, e.Err)
+}
+
+func (e *APIError) Unwrap() error {
+	return e.Err
+}
+
+// WrapAPIError attaches the status code of an API response to an error from
+// the request, so that it can be translated to an errno later
+func WrapAPIError(err error, resp *http.Response) error {
+	if err == nil {
+		return nil
+	}
+	if resp == nil {
+		return err
+	}
+	return &APIError{StatusCode: resp.StatusCode, Err: err}
+}
+
+// Matches the status that valgo starts its errors with, like "404 Not Found"
+var statusErrorRe = regexp.MustCompile(`^(\d{3}) `)
+
+// statusCodeOf returns the HTTP status code of the response an error came
+// from, or 0 if it is unknown
+func statusCodeOf(err error) int {
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		return apiErr.StatusCode
+	}
+
+	if matches := statusErrorRe.FindStringSubmatch(err.Error()); matches != nil {
+		statusCode, _ := strconv.Atoi(matches[1])
+		return statusCode
+	}
+
+	return 0
+}
+
+// ToErrno translates an error from the val town API into the errno that best
+// describes it, so that programs using the file system can react to it.
+// Callers log the error themselves, so the mapping is only logged at debug
+// level.
+func ToErrno(err error) syscall.Errno {
+	if err == nil {
+		return syscall.F_OK
+	}
+
+	statusCode := statusCodeOf(err)
+	errno := syscall.EIO
+
+	var netErr net.Error
+	switch {
+	case errors.Is(err, context.DeadlineExceeded):
+		errno = syscall.ETIMEDOUT
+	case errors.As(err, &netErr) && netErr.Timeout():
+		errno = syscall.ETIMEDOUT
+	case errors.Is(err, context.Canceled):
+		errno = syscall.EINTR
+	case statusCode == http.StatusBadRequest:
+		errno = syscall.EINVAL
+	case statusCode == http.StatusUnauthorized, statusCode == http.StatusForbidden:
+		errno = syscall.EACCES
+	case statusCode == http.StatusNotFound:
+		errno = syscall.ENOENT
+	case statusCode == http.StatusConflict:
+		errno = syscall.EEXIST
+	case statusCode == http.StatusRequestEntityTooLarge:
+		errno = syscall.EFBIG
+	case statusCode == http.StatusTooManyRequests:
+		errno = syscall.EAGAIN
+	}
+
+	Logger.Debugw(
+		"Mapped Val Town API error to errno",
+		"error", err,
+		"status", statusCode,
+		"errno", errno.Error(),
+	)
+
+	return errno
+}
@@ -0,0 +1,46 @@
+package common
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"syscall"
+	"testing"
+
+	"go.uber.org/zap"
+)
+
+func TestToErrno(t *testing.T) {
+	Logger = zap.NewNop().Sugar()
+
+	statusError := func(statusCode int) error {
+		return WrapAPIError(errors.New("request failed"), &http.Response{StatusCode: statusCode})
+	}
+
+	cases := []struct {
+		name string
+		err  error
+		want syscall.Errno
+	}{
+		{"nil", nil, syscall.F_OK},
+		{"not found", statusError(http.StatusNotFound), syscall.ENOENT},
+		{"unauthorized", statusError(http.StatusUnauthorized), syscall.EACCES},
+		{"forbidden", statusError(http.StatusForbidden), syscall.EACCES},
+		{"conflict", statusError(http.StatusConflict), syscall.EEXIST},
+		{"too large", statusError(http.StatusRequestEntityTooLarge), syscall.EFBIG},
+		{"rate limited", statusError(http.StatusTooManyRequests), syscall.EAGAIN},
+		{"server error", statusError(http.StatusInternalServerError), syscall.EIO},
+		{"status in message", errors.New("404 Not Found"), syscall.ENOENT},
+		{"timeout", fmt.Errorf("request: %w", context.DeadlineExceeded), syscall.ETIMEDOUT},
+		{"unknown", errors.New("something broke"), syscall.EIO},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if got := ToErrno(c.err); got != c.want {
+				t.Errorf("ToErrno(%v) = %v, want %v", c.err, got, c.want)
+			}
+		})
+	}
+}
@@ -1,10 +1,14 @@
 package common
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
+	"fmt"
 	"io"
 	"net/http"
 	"net/url"
+	"strings"
 
 	"github.com/404wolf/valgo"
 )
@@ -39,7 +43,13 @@ func (c *APIClient) RawRequest(
 	if err != nil {
 		return nil, err
 	}
-	u.Path = path
+	// The path can have a query string, like /v1/search/vals?query=openai
+	ref, err := url.Parse(path)
+	if err != nil {
+		return nil, err
+	}
+	u.Path = ref.Path
+	u.RawQuery = ref.RawQuery
 	req, err := http.NewRequestWithContext(ctx, method, u.String(), body)
 	if err != nil {
 		return nil, err
@@ -50,6 +60,10 @@ func (c *APIClient) RawRequest(
 		req.Header.Add(k, v)
 	}
 
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
 	// Set User-Agent
 	if c.cfg.UserAgent != "" {
 		req.Header.Set("User-Agent", c.cfg.UserAgent)
@@ -64,3 +78,43 @@ func (c *APIClient) RawRequest(
 	// Send the request
 	return client.Do(req)
 }
+
+// RawJSONRequest makes a request to an endpoint that valgo doesn't cover, with
+// in encoded as the JSON body (if it isn't nil), and decodes the JSON response
+// into out (if it isn't nil). Responses that aren't successful are returned as
+// an APIError with the body of the response as the message.
+func (c *APIClient) RawJSONRequest(
+	ctx context.Context,
+	method, path string,
+	in, out any,
+) error {
+	var body io.Reader
+	if in != nil {
+		encoded, err := json.Marshal(in)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewReader(encoded)
+	}
+
+	resp, err := c.RawRequest(ctx, method, path, body)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		message, _ := io.ReadAll(resp.Body)
+		return &APIError{
+			StatusCode: resp.StatusCode,
+			Err:        fmt.Errorf("%s: %s", resp.Status, strings.TrimSpace(string(message))),
+		}
+	}
+
+	if out == nil {
+		return nil
+	}
+	decoder := json.NewDecoder(resp.Body)
+	decoder.UseNumber() // Keep large integers exact
+	return decoder.Decode(out)
+}
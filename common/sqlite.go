@@ -0,0 +1,49 @@
+package common
+
+import (
+	"context"
+	"net/http"
+)
+
+// SqliteResult is the result of running a statement against val town's
+// SQLite database. Values in rows are decoded from JSON, with numbers as
+// json.Number.
+type SqliteResult struct {
+	Columns      []string `json:"columns"`
+	ColumnTypes  []string `json:"columnTypes"`
+	Rows         [][]any  `json:"rows"`
+	RowsAffected int64    `json:"rowsAffected"`
+}
+
+// SqliteExecute runs a single SQL statement against val town's SQLite database
+func (c *APIClient) SqliteExecute(ctx context.Context, statement string) (*SqliteResult, error) {
+	result := &SqliteResult{}
+	err := c.RawJSONRequest(ctx, http.MethodPost, "/v1/sqlite/execute", map[string]any{
+		"statement": statement,
+	}, result)
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// The modes that a batch of statements can be run in
+const (
+	SqliteWrite    = "write"
+	SqliteRead     = "read"
+	SqliteDeferred = "deferred"
+)
+
+// SqliteBatch runs SQL statements against val town's SQLite database in a
+// single transaction, so either all or none of them are applied
+func (c *APIClient) SqliteBatch(ctx context.Context, statements []string, mode string) ([]SqliteResult, error) {
+	results := []SqliteResult{}
+	err := c.RawJSONRequest(ctx, http.MethodPost, "/v1/sqlite/batch", map[string]any{
+		"statements": statements,
+		"mode":       mode,
+	}, &results)
+	if err != nil {
+		return nil, err
+	}
+	return results, nil
+}
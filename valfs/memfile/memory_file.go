@@ -0,0 +1,202 @@
+package valfs
+
+import (
+	"context"
+	"sync"
+	"syscall"
+
+	"github.com/hanwen/go-fuse/v2/fs"
+	"github.com/hanwen/go-fuse/v2/fuse"
+)
+
+// SaveFunc is called with the contents of a memory file when it is saved as
+// name, and says whether the file should become read only after that
+type SaveFunc func(
+	ctx context.Context,
+	file *MemoryFile,
+	name string,
+	data []byte,
+) (readOnly bool, errno syscall.Errno)
+
+// MemoryFile is a file that is only kept in memory. It is saved when it is
+// closed after being written to, or when it is saved explicitly, like when it
+// is renamed.
+type MemoryFile struct {
+	fs.Inode
+
+	save SaveFunc // What to do with the file when it is saved, if anything
+
+	mutex    sync.Mutex
+	data     []byte
+	dirty    bool // Whether the file was written to since it was last saved
+	readOnly bool
+}
+
+var _ = (fs.NodeOpener)((*MemoryFile)(nil))
+var _ = (fs.NodeReader)((*MemoryFile)(nil))
+var _ = (fs.NodeWriter)((*MemoryFile)(nil))
+var _ = (fs.NodeGetattrer)((*MemoryFile)(nil))
+var _ = (fs.NodeSetattrer)((*MemoryFile)(nil))
+var _ = (fs.NodeFlusher)((*MemoryFile)(nil))
+
+// NewMemoryFile creates an empty file that calls save when it is saved
+func NewMemoryFile(save SaveFunc) *MemoryFile {
+	return &MemoryFile{save: save}
+}
+
+// NewReadOnlyMemoryFile creates a read only file with data in it
+func NewReadOnlyMemoryFile(data []byte) *MemoryFile {
+	return &MemoryFile{data: data, readOnly: true}
+}
+
+// Set replaces the contents of the file
+func (f *MemoryFile) Set(data []byte) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	f.data = data
+}
+
+// Save saves the file as name, unless it is read only. The file can't be
+// changed while it is being saved.
+func (f *MemoryFile) Save(ctx context.Context, name string) syscall.Errno {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	if f.readOnly || f.save == nil {
+		return syscall.F_OK
+	}
+	f.dirty = false
+
+	readOnly, errno := f.save(ctx, f, name, f.data)
+	f.readOnly = readOnly
+	return errno
+}
+
+// Open opens the file, emptying it first if it is opened with O_TRUNC
+func (f *MemoryFile) Open(ctx context.Context, openFlags uint32) (
+	fh fs.FileHandle,
+	fuseFlags uint32,
+	errno syscall.Errno,
+) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	if f.readOnly && openFlags&(syscall.O_WRONLY|syscall.O_RDWR|syscall.O_TRUNC) != 0 {
+		return nil, 0, syscall.EACCES
+	}
+	if openFlags&syscall.O_TRUNC != 0 {
+		f.data = nil
+		f.dirty = true
+	}
+	return nil, fuse.FOPEN_DIRECT_IO, syscall.F_OK
+}
+
+// Read reads the file
+func (f *MemoryFile) Read(
+	ctx context.Context,
+	fh fs.FileHandle,
+	dest []byte,
+	off int64,
+) (fuse.ReadResult, syscall.Errno) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	return ReadAt(f.data, dest, off), syscall.F_OK
+}
+
+// Write writes to the file at an offset
+func (f *MemoryFile) Write(
+	ctx context.Context,
+	fh fs.FileHandle,
+	data []byte,
+	off int64,
+) (uint32, syscall.Errno) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	if f.readOnly {
+		return 0, syscall.EACCES
+	}
+
+	f.resize(max(off+int64(len(data)), int64(len(f.data))))
+	copy(f.data[off:], data)
+	f.dirty = true
+
+	return uint32(len(data)), syscall.F_OK
+}
+
+// Getattr gives the attributes of the file
+func (f *MemoryFile) Getattr(
+	ctx context.Context,
+	fh fs.FileHandle,
+	out *fuse.AttrOut,
+) syscall.Errno {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	out.Mode = 0644
+	if f.readOnly {
+		out.Mode = 0444
+	}
+	out.Size = uint64(len(f.data))
+	return syscall.F_OK
+}
+
+// Setattr handles truncating the file
+func (f *MemoryFile) Setattr(
+	ctx context.Context,
+	fh fs.FileHandle,
+	in *fuse.SetAttrIn,
+	out *fuse.AttrOut,
+) syscall.Errno {
+	if size, ok := in.GetSize(); ok {
+		f.mutex.Lock()
+		if f.readOnly {
+			f.mutex.Unlock()
+			return syscall.EACCES
+		}
+		f.resize(int64(size))
+		f.dirty = true
+		f.mutex.Unlock()
+	}
+
+	return f.Getattr(ctx, fh, out)
+}
+
+// Flush saves the file once it is closed, if it was written to
+func (f *MemoryFile) Flush(ctx context.Context, fh fs.FileHandle) syscall.Errno {
+	name, parent := f.Parent()
+	if parent == nil {
+		return syscall.F_OK
+	}
+
+	f.mutex.Lock()
+	dirty := f.dirty
+	f.mutex.Unlock()
+
+	if !dirty {
+		return syscall.F_OK
+	}
+	return f.Save(ctx, name)
+}
+
+// resize grows or shrinks the file, padding it with zeros. The mutex must be
+// held.
+func (f *MemoryFile) resize(size int64) {
+	if size <= int64(len(f.data)) {
+		f.data = f.data[:size]
+		return
+	}
+	f.data = append(f.data, make([]byte, size-int64(len(f.data)))...)
+}
+
+// ReadAt reads the part of bytes that dest has room for, starting at off
+func ReadAt(bytes []byte, dest []byte, off int64) fuse.ReadResult {
+	if off > int64(len(bytes)) {
+		off = int64(len(bytes))
+	}
+	end := off + int64(len(dest))
+	if end > int64(len(bytes)) {
+		end = int64(len(bytes))
+	}
+	return fuse.ReadResultData(bytes[off:end])
+}
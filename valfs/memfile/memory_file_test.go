@@ -0,0 +1,54 @@
+package valfs
+
+import (
+	"context"
+	"syscall"
+	"testing"
+
+	"github.com/hanwen/go-fuse/v2/fuse"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMemoryFileSave(t *testing.T) {
+	ctx := context.Background()
+
+	saved := []string{}
+	file := NewMemoryFile(func(
+		ctx context.Context,
+		file *MemoryFile,
+		name string,
+		data []byte,
+	) (bool, syscall.Errno) {
+		saved = append(saved, name+": "+string(data))
+		return name == "final.txt", syscall.F_OK
+	})
+
+	_, errno := file.Write(ctx, nil, []byte("hello"), 0)
+	require.Zero(t, errno)
+	_, errno = file.Write(ctx, nil, []byte("p!"), 3)
+	require.Zero(t, errno)
+
+	assert.Zero(t, file.Save(ctx, "draft.txt"))
+	assert.Zero(t, file.Save(ctx, "final.txt"))
+	assert.Zero(t, file.Save(ctx, "again.txt"), "Read only files shouldn't be saved")
+	assert.Equal(t, []string{"draft.txt: help!", "final.txt: help!"}, saved)
+
+	_, errno = file.Write(ctx, nil, []byte("more"), 0)
+	assert.Equal(t, syscall.EACCES, errno, "Saved files that became read only shouldn't be writable")
+
+	out := &fuse.AttrOut{}
+	file.Getattr(ctx, nil, out)
+	assert.Equal(t, uint32(0444), out.Mode)
+	assert.Equal(t, uint64(5), out.Size)
+}
+
+func TestReadAt(t *testing.T) {
+	read := func(off int64, size int) string {
+		data, _ := ReadAt([]byte("hello"), make([]byte, size), off).Bytes(nil)
+		return string(data)
+	}
+	assert.Equal(t, "hel", read(0, 3))
+	assert.Equal(t, "lo", read(3, 10))
+	assert.Equal(t, "", read(10, 3), "Reads past the end should be empty")
+}
@@ -0,0 +1,75 @@
+package valfs
+
+import (
+	"context"
+	"syscall"
+
+	common "github.com/404wolf/valfs/common"
+	"github.com/hanwen/go-fuse/v2/fs"
+	"github.com/hanwen/go-fuse/v2/fuse"
+)
+
+// RenderedFile is a read only file whose contents are rendered when it is
+// opened, like the rows of a table. Its size isn't known until then, so it is
+// read with direct IO.
+type RenderedFile struct {
+	fs.Inode
+
+	render func(ctx context.Context) ([]byte, error)
+}
+
+var _ = (fs.NodeOpener)((*RenderedFile)(nil))
+var _ = (fs.NodeGetattrer)((*RenderedFile)(nil))
+
+// renderedHandle holds the contents of a rendered file while it is open
+type renderedHandle struct {
+	data []byte
+}
+
+var _ = (fs.FileReader)((*renderedHandle)(nil))
+
+// NewRenderedFile creates a read only file that is rendered on every open
+func NewRenderedFile(render func(ctx context.Context) ([]byte, error)) *RenderedFile {
+	return &RenderedFile{render: render}
+}
+
+// Getattr gives the attributes of the file
+func (f *RenderedFile) Getattr(
+	ctx context.Context,
+	fh fs.FileHandle,
+	out *fuse.AttrOut,
+) syscall.Errno {
+	out.Mode = 0444
+	if handle, ok := fh.(*renderedHandle); ok {
+		out.Size = uint64(len(handle.data))
+	}
+	return syscall.F_OK
+}
+
+// Open renders the contents of the file
+func (f *RenderedFile) Open(ctx context.Context, openFlags uint32) (
+	fh fs.FileHandle,
+	fuseFlags uint32,
+	errno syscall.Errno,
+) {
+	if openFlags&(syscall.O_WRONLY|syscall.O_RDWR|syscall.O_TRUNC) != 0 {
+		return nil, 0, syscall.EROFS
+	}
+
+	data, err := f.render(ctx)
+	if err != nil {
+		common.Logger.Errorf("Error rendering file: %v", err)
+		return nil, 0, common.ToErrno(err)
+	}
+
+	return &renderedHandle{data: data}, fuse.FOPEN_DIRECT_IO, syscall.F_OK
+}
+
+// Read reads the rendered contents
+func (fh *renderedHandle) Read(
+	ctx context.Context,
+	dest []byte,
+	off int64,
+) (fuse.ReadResult, syscall.Errno) {
+	return ReadAt(fh.data, dest, off), syscall.F_OK
+}
@@ -0,0 +1,173 @@
+package valfs
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/mail"
+	"path/filepath"
+	"strings"
+
+	common "github.com/404wolf/valfs/common"
+	"github.com/goccy/go-yaml"
+)
+
+// The extensions of the files that are sent as emails
+var EmailExtensions = []string{".eml", ".yaml", ".yml", ".md"}
+
+// IsEmailFile checks whether a file is sent as an email when it is written
+func IsEmailFile(name string) bool {
+	for _, extension := range EmailExtensions {
+		if strings.HasSuffix(name, extension) && !strings.HasPrefix(name, ".") {
+			return true
+		}
+	}
+	return false
+}
+
+// ParseEmail reads an email from a file in the outbox. The format depends on
+// the extension of the file name: .eml files are MIME messages, .yaml files
+// have subject, body and html fields, and .md files have a subject in their
+// frontmatter and the body after it. Emails without a subject get the file
+// name as their subject.
+func ParseEmail(name string, contents []byte) (common.Email, error) {
+	var email common.Email
+	var err error
+
+	switch filepath.Ext(name) {
+	case ".eml":
+		email, err = parseEml(contents)
+	case ".yaml", ".yml":
+		email, err = parseYaml(contents)
+	case ".md":
+		email, err = parseMarkdown(contents)
+	default:
+		return email, fmt.Errorf("%s isn't an email file, use one of %s", name, strings.Join(EmailExtensions, ", "))
+	}
+	if err != nil {
+		return email, err
+	}
+
+	if email.Subject == "" {
+		email.Subject = strings.TrimSuffix(name, filepath.Ext(name))
+	}
+	if email.Text == "" && email.HTML == "" {
+		return email, fmt.Errorf("email has no body")
+	}
+	return email, nil
+}
+
+// The fields of a YAML email, and of the frontmatter of a markdown email
+type emailFields struct {
+	Subject string `yaml:"subject"`
+	Body    string `yaml:"body"`
+	HTML    string `yaml:"html"`
+}
+
+// parseYaml reads an email from YAML fields
+func parseYaml(contents []byte) (common.Email, error) {
+	fields := emailFields{}
+	if err := yaml.UnmarshalWithOptions(contents, &fields, yaml.Strict()); err != nil {
+		return common.Email{}, fmt.Errorf("invalid email YAML: %w", err)
+	}
+	return common.Email{Subject: fields.Subject, Text: fields.Body, HTML: fields.HTML}, nil
+}
+
+// parseMarkdown reads an email from markdown, with optional YAML frontmatter
+// between --- lines for the subject
+func parseMarkdown(contents []byte) (common.Email, error) {
+	text := string(contents)
+	fields := emailFields{}
+
+	if rest, ok := strings.CutPrefix(text, "---\n"); ok {
+		frontmatter, body, found := strings.Cut(rest, "\n---\n")
+		if !found {
+			return common.Email{}, fmt.Errorf("frontmatter isn't closed with ---")
+		}
+		if err := yaml.UnmarshalWithOptions([]byte(frontmatter), &fields, yaml.Strict()); err != nil {
+			return common.Email{}, fmt.Errorf("invalid email frontmatter: %w", err)
+		}
+		text = body
+	}
+
+	return common.Email{
+		Subject: fields.Subject,
+		Text:    strings.TrimLeft(text, "\n"),
+		HTML:    fields.HTML,
+	}, nil
+}
+
+// parseEml reads an email from a MIME message, using its text and HTML parts
+func parseEml(contents []byte) (common.Email, error) {
+	message, err := mail.ReadMessage(bytes.NewReader(contents))
+	if err != nil {
+		return common.Email{}, fmt.Errorf("invalid email message: %w", err)
+	}
+
+	decoder := new(mime.WordDecoder)
+	subject, err := decoder.DecodeHeader(message.Header.Get("Subject"))
+	if err != nil {
+		subject = message.Header.Get("Subject")
+	}
+
+	email := common.Email{Subject: subject}
+	err = readPart(&email, message.Header, message.Body)
+	return email, err
+}
+
+// The headers of a message or of a part of one
+type partHeader interface {
+	Get(key string) string
+}
+
+// readPart sets the text or HTML of an email from a part of a MIME message,
+// going into multipart parts
+func readPart(email *common.Email, header partHeader, body io.Reader) error {
+	mediaType, params, err := mime.ParseMediaType(header.Get("Content-Type"))
+	if err != nil {
+		mediaType = "text/plain"
+	}
+
+	if strings.HasPrefix(mediaType, "multipart/") {
+		reader := multipart.NewReader(body, params["boundary"])
+		for {
+			part, err := reader.NextPart()
+			if err == io.EOF {
+				return nil
+			}
+			if err != nil {
+				return fmt.Errorf("invalid email part: %w", err)
+			}
+			if err := readPart(email, part.Header, part); err != nil {
+				return err
+			}
+		}
+	}
+
+	switch strings.ToLower(header.Get("Content-Transfer-Encoding")) {
+	case "quoted-printable":
+		body = quotedprintable.NewReader(body)
+	case "base64":
+		body = base64.NewDecoder(base64.StdEncoding, body)
+	}
+
+	data, err := io.ReadAll(body)
+	if err != nil {
+		return err
+	}
+	switch mediaType {
+	case "text/plain":
+		if email.Text == "" {
+			email.Text = string(data)
+		}
+	case "text/html":
+		if email.HTML == "" {
+			email.HTML = string(data)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,88 @@
+package valfs
+
+import (
+	"testing"
+
+	common "github.com/404wolf/valfs/common"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseEmail(t *testing.T) {
+	tests := []struct {
+		name     string
+		filename string
+		contents string
+		expected common.Email
+	}{
+		{
+			"yaml",
+			"deploy.yaml",
+			"subject: Deployed\nbody: |\n  It worked!\n",
+			common.Email{Subject: "Deployed", Text: "It worked!\n"},
+		},
+		{
+			"yaml without subject",
+			"backup-done.yml",
+			"html: <b>Done</b>\n",
+			common.Email{Subject: "backup-done", HTML: "<b>Done</b>"},
+		},
+		{
+			"markdown with frontmatter",
+			"report.md",
+			"---\nsubject: Weekly report\n---\n\n# Report\n\nAll good.\n",
+			common.Email{Subject: "Weekly report", Text: "# Report\n\nAll good.\n"},
+		},
+		{
+			"markdown without frontmatter",
+			"note.md",
+			"Remember to renew the domain\n",
+			common.Email{Subject: "note", Text: "Remember to renew the domain\n"},
+		},
+		{
+			"eml",
+			"alert.eml",
+			"Subject: =?UTF-8?Q?Disk_almost_full_=E2=9A=A0?=\r\nContent-Type: text/plain\r\n\r\nOnly 1GB left.\r\n",
+			common.Email{Subject: "Disk almost full ⚠", Text: "Only 1GB left.\r\n"},
+		},
+		{
+			"multipart eml",
+			"both.eml",
+			"Subject: Both\r\n" +
+				"Content-Type: multipart/alternative; boundary=b\r\n\r\n" +
+				"--b\r\nContent-Type: text/plain\r\n\r\nPlain\r\n" +
+				"--b\r\nContent-Type: text/html\r\nContent-Transfer-Encoding: base64\r\n\r\nPGI+SHRtbDwvYj4=\r\n" +
+				"--b--\r\n",
+			common.Email{Subject: "Both", Text: "Plain", HTML: "<b>Html</b>"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			email, err := ParseEmail(test.filename, []byte(test.contents))
+			assert.NoError(t, err)
+			assert.Equal(t, test.expected, email)
+		})
+	}
+}
+
+func TestParseEmailErrors(t *testing.T) {
+	_, err := ParseEmail("empty.yaml", []byte("subject: Nothing\n"))
+	assert.Error(t, err, "emails need a body")
+
+	_, err = ParseEmail("typo.yaml", []byte("subjet: Oops\nbody: Hi\n"))
+	assert.Error(t, err, "unknown fields are refused")
+
+	_, err = ParseEmail("open.md", []byte("---\nsubject: Never closed\nHi\n"))
+	assert.Error(t, err, "frontmatter must be closed")
+
+	_, err = ParseEmail("notes.txt", []byte("Hi\n"))
+	assert.Error(t, err, "only email files are sent")
+}
+
+func TestIsEmailFile(t *testing.T) {
+	assert.True(t, IsEmailFile("alert.eml"))
+	assert.True(t, IsEmailFile("report.md"))
+	assert.False(t, IsEmailFile(".report.md.swp"))
+	assert.False(t, IsEmailFile(".hidden.md"))
+	assert.False(t, IsEmailFile("report.md.error"))
+}
@@ -0,0 +1,176 @@
+package valfs
+
+import (
+	"context"
+	"syscall"
+	"time"
+
+	common "github.com/404wolf/valfs/common"
+	memfile "github.com/404wolf/valfs/valfs/memfile"
+	"github.com/hanwen/go-fuse/v2/fs"
+	"github.com/hanwen/go-fuse/v2/fuse"
+)
+
+// The name of the directory that sent emails are moved to
+const SentDirname = "sent"
+
+// OutboxDir is a directory where writing an email file sends it to the owner
+// of the val town account. Once an email is sent its file is moved to sent/,
+// and if sending fails, the error shows up next to it in name.error.
+type OutboxDir struct {
+	fs.Inode
+
+	client  *common.Client
+	sentDir *SentDir
+}
+
+var _ = (fs.NodeCreater)((*OutboxDir)(nil))
+var _ = (fs.NodeUnlinker)((*OutboxDir)(nil))
+var _ = (fs.NodeRenamer)((*OutboxDir)(nil))
+
+// SentDir is the directory of emails that were sent. Its files are read only,
+// but can be removed.
+type SentDir struct {
+	fs.Inode
+}
+
+var _ = (fs.NodeUnlinker)((*SentDir)(nil))
+
+// NewOutboxDir creates a directory for sending emails
+func NewOutboxDir(ctx context.Context, parent *fs.Inode, client *common.Client) *OutboxDir {
+	outboxDir := &OutboxDir{client: client, sentDir: &SentDir{}}
+	parent.NewPersistentInode(ctx, outboxDir, fs.StableAttr{Mode: syscall.S_IFDIR | 0755})
+
+	outboxDir.NewPersistentInode(ctx, outboxDir.sentDir, fs.StableAttr{Mode: syscall.S_IFDIR | 0755})
+	outboxDir.AddChild(SentDirname, &outboxDir.sentDir.Inode, true)
+
+	return outboxDir
+}
+
+// Create makes a new file. Files that aren't emails are allowed too, so that
+// editors can make their swap and backup files.
+func (c *OutboxDir) Create(
+	ctx context.Context,
+	name string,
+	flags uint32,
+	mode uint32,
+	entryOut *fuse.EntryOut,
+) (inode *fs.Inode, fh fs.FileHandle, fuseFlags uint32, code syscall.Errno) {
+	outboxFile := memfile.NewMemoryFile(c.send)
+	inode = c.NewPersistentInode(ctx, outboxFile, fs.StableAttr{Mode: syscall.S_IFREG})
+	entryOut.Attr.Mode = 0644
+	return inode, nil, 0, syscall.F_OK
+}
+
+// Unlink removes a file from the outbox
+func (c *OutboxDir) Unlink(ctx context.Context, name string) syscall.Errno {
+	if name == SentDirname {
+		return syscall.EISDIR
+	}
+	return syscall.F_OK
+}
+
+// Rename moves a file within the outbox, and sends it if it becomes an email
+// file, since many editors save by renaming a new file over the old one
+func (c *OutboxDir) Rename(
+	ctx context.Context,
+	oldName string,
+	newParent fs.InodeEmbedder,
+	newName string,
+	flags uint32,
+) syscall.Errno {
+	if newParent.EmbeddedInode() != &c.Inode || oldName == SentDirname || newName == SentDirname {
+		return syscall.EXDEV
+	}
+
+	child := c.GetChild(oldName)
+	if child == nil {
+		return syscall.ENOENT
+	}
+
+	// A file that is sent is moved to sent/ straight from its old name, so the
+	// move that follows this finds nothing to move. The file it would have
+	// replaced is removed here instead.
+	if outboxFile, ok := child.Operations().(*memfile.MemoryFile); ok && IsEmailFile(newName) {
+		outboxFile.Save(ctx, newName)
+		if c.GetChild(oldName) == nil {
+			c.RmChild(newName)
+			go c.NotifyEntry(newName)
+		}
+	}
+	return syscall.F_OK
+}
+
+// Unlink removes a sent email
+func (c *SentDir) Unlink(ctx context.Context, name string) syscall.Errno {
+	return syscall.F_OK
+}
+
+// moveToSent moves a file that was sent as the email name into the sent
+// directory, adding the time it was sent to its name if another email there
+// already has that name
+func (c *OutboxDir) moveToSent(currentName string, name string) {
+	sentName := name
+	if c.sentDir.GetChild(sentName) != nil {
+		sentName = time.Now().Format("2006-01-02T15-04-05") + "_" + name
+	}
+
+	c.MvChild(currentName, &c.sentDir.Inode, sentName, true)
+	c.RmChild(errorFilename(name))
+	go c.NotifyEntry(currentName)
+	go c.NotifyEntry(errorFilename(name))
+}
+
+// setError shows why sending an email failed in the file next to it
+func (c *OutboxDir) setError(ctx context.Context, name string, err error) {
+	filename := errorFilename(name)
+	data := []byte(err.Error() + "\n")
+	if child := c.GetChild(filename); child != nil {
+		if errorFile, ok := child.Operations().(*memfile.MemoryFile); ok {
+			errorFile.Set(data)
+			return
+		}
+	}
+
+	errorFile := memfile.NewReadOnlyMemoryFile(data)
+	c.NewPersistentInode(ctx, errorFile, fs.StableAttr{Mode: syscall.S_IFREG})
+	c.AddChild(filename, &errorFile.Inode, true)
+	go c.NotifyEntry(filename)
+}
+
+// errorFilename is the name of the file with the error from sending an email
+func errorFilename(name string) string {
+	return name + ".error"
+}
+
+// send sends a file in the outbox as the email name, if it is an email file,
+// and moves it from its current name to the sent directory. Sent files are
+// read only.
+func (c *OutboxDir) send(
+	ctx context.Context,
+	file *memfile.MemoryFile,
+	name string,
+	data []byte,
+) (readOnly bool, errno syscall.Errno) {
+	currentName, parent := file.Parent()
+	if parent != &c.Inode || !IsEmailFile(name) {
+		return false, syscall.F_OK
+	}
+
+	email, err := ParseEmail(name, data)
+	if err != nil {
+		common.Logger.Errorf("Error parsing email %s: %v", name, err)
+		c.setError(ctx, name, err)
+		return false, syscall.EINVAL
+	}
+
+	common.Logger.Infof("Sending email %s", name)
+	if err := c.client.APIClient.SendEmail(ctx, email); err != nil {
+		common.Logger.Errorf("Error sending email %s: %v", name, err)
+		c.setError(ctx, name, err)
+		return false, common.ToErrno(err)
+	}
+
+	c.moveToSent(currentName, name)
+	return true, syscall.F_OK
+}
@@ -0,0 +1,241 @@
+package valfs
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+
+	common "github.com/404wolf/valfs/common"
+)
+
+// Gets the name and creating statement of everything in the database, with
+// tables first so that their rows can be inserted before indexes and triggers
+// are created
+const dumpSchemaSQL = `SELECT type, name, sql FROM sqlite_master WHERE sql IS NOT NULL AND name NOT LIKE 'sqlite_%' ORDER BY type = 'table' DESC, name`
+
+// Dump writes SQL that recreates val town's SQLite database, with the schema
+// and the rows of every table, like sqlite3's .dump
+func Dump(ctx context.Context, apiClient *common.APIClient, w io.Writer) error {
+	schema, err := apiClient.SqliteExecute(ctx, dumpSchemaSQL)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintln(w, "-- Dump of a Val Town SQLite database, made by valfs")
+	for _, row := range schema.Rows {
+		objectType, _ := row[0].(string)
+		name, _ := row[1].(string)
+		sql, _ := row[2].(string)
+
+		if _, err := fmt.Fprintf(w, "%s;\n", sql); err != nil {
+			return err
+		}
+		if objectType != "table" {
+			continue
+		}
+
+		rows, err := apiClient.SqliteExecute(ctx, "SELECT * FROM "+QuoteIdentifier(name))
+		if err != nil {
+			return err
+		}
+		inserts, err := insertStatements(name, rows)
+		if err != nil {
+			return err
+		}
+		for _, insert := range inserts {
+			if _, err := fmt.Fprintf(w, "%s;\n", insert); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// Restore runs the statements of a dump, or of any SQL file, against val
+// town's SQLite database. They run in a single transaction, so nothing is
+// changed if one of them fails.
+func Restore(ctx context.Context, apiClient *common.APIClient, sql string) (int, error) {
+	statements := SplitStatements(sql)
+	if len(statements) == 0 {
+		return 0, nil
+	}
+
+	if _, err := apiClient.SqliteBatch(ctx, statements, common.SqliteWrite); err != nil {
+		return 0, err
+	}
+	return len(statements), nil
+}
+
+// insertStatements makes a statement that inserts each of the rows of a table
+func insertStatements(table string, result *common.SqliteResult) ([]string, error) {
+	columns := make([]string, len(result.Columns))
+	for i, column := range result.Columns {
+		columns[i] = QuoteIdentifier(column)
+	}
+
+	inserts := make([]string, len(result.Rows))
+	for i, row := range result.Rows {
+		values := make([]string, len(row))
+		for j, value := range row {
+			literal, err := sqlLiteral(value)
+			if err != nil {
+				return nil, fmt.Errorf("dumping column %s of %s: %w", result.Columns[j], table, err)
+			}
+			values[j] = literal
+		}
+		inserts[i] = fmt.Sprintf(
+			"INSERT INTO %s (%s) VALUES (%s)",
+			QuoteIdentifier(table),
+			strings.Join(columns, ", "),
+			strings.Join(values, ", "),
+		)
+	}
+	return inserts, nil
+}
+
+// sqlLiteral writes a value from a result as a SQL literal. Blobs come back as
+// arrays of their bytes, and are written as hex blob literals. Anything else
+// is an error, rather than a literal that would restore as something else.
+func sqlLiteral(value any) (string, error) {
+	switch value := value.(type) {
+	case nil:
+		return "NULL", nil
+	case json.Number:
+		return value.String(), nil
+	case bool:
+		if value {
+			return "1", nil
+		}
+		return "0", nil
+	case string:
+		return stringLiteral(value), nil
+	case []any:
+		blob := make([]byte, len(value))
+		for i, element := range value {
+			number, ok := element.(json.Number)
+			if !ok {
+				return "", fmt.Errorf("blob has a non-numeric byte %v", element)
+			}
+			b, err := strconv.ParseUint(number.String(), 10, 8)
+			if err != nil {
+				return "", fmt.Errorf("blob has an invalid byte %s", number)
+			}
+			blob[i] = byte(b)
+		}
+		return "X'" + strings.ToUpper(hex.EncodeToString(blob)) + "'", nil
+	default:
+		return "", fmt.Errorf("can't write %T value %v as SQL", value, value)
+	}
+}
+
+// stringLiteral quotes a string as a SQL string literal
+func stringLiteral(value string) string {
+	return "'" + strings.ReplaceAll(value, "'", "''") + "'"
+}
+
+// Matches the start of a statement that creates a trigger, whose body has
+// semicolons in it
+var createTriggerRe = regexp.MustCompile(`(?i)^CREATE\s+(TEMP\s+|TEMPORARY\s+)?TRIGGER\b`)
+
+// isWordChar reports whether a character can be part of an unquoted name or
+// keyword
+func isWordChar(char byte) bool {
+	return char == '_' || char == '$' ||
+		('a' <= char && char <= 'z') || ('A' <= char && char <= 'Z') || ('0' <= char && char <= '9')
+}
+
+// SplitStatements splits SQL into its statements, at the semicolons that
+// aren't in strings, quoted names, comments or the bodies of triggers.
+// Comments are left out, along with empty statements.
+func SplitStatements(sql string) []string {
+	statements := []string{}
+	var current strings.Builder
+	// How many BEGIN or CASE blocks are open without their END, since the
+	// body of a trigger only ends at the END of its BEGIN
+	depth := 0
+
+	endStatement := func() {
+		statement := strings.TrimSpace(current.String())
+		if statement != "" {
+			statements = append(statements, statement)
+		}
+		current.Reset()
+		depth = 0
+	}
+
+	for i := 0; i < len(sql); i++ {
+		char := sql[i]
+		switch {
+		case char == '-' && strings.HasPrefix(sql[i:], "--"):
+			end := strings.IndexByte(sql[i:], '\n')
+			if end == -1 {
+				i = len(sql)
+			} else {
+				i += end
+				current.WriteByte('\n')
+			}
+		case char == '/' && strings.HasPrefix(sql[i:], "/*"):
+			end := strings.Index(sql[i+2:], "*/")
+			if end == -1 {
+				i = len(sql)
+			} else {
+				i += end + 3
+				current.WriteByte(' ')
+			}
+		case char == '\'' || char == '"' || char == '`' || char == '[':
+			closing := char
+			if char == '[' {
+				closing = ']'
+			}
+			end := i + 1
+			for end < len(sql) {
+				if sql[end] == closing {
+					// Quotes are escaped by doubling them
+					if closing != ']' && end+1 < len(sql) && sql[end+1] == closing {
+						end += 2
+						continue
+					}
+					break
+				}
+				end++
+			}
+			if end >= len(sql) {
+				end = len(sql) - 1
+			}
+			current.WriteString(sql[i : end+1])
+			i = end
+		case char == ';':
+			statement := strings.TrimSpace(current.String())
+			if depth > 0 && createTriggerRe.MatchString(statement) {
+				current.WriteByte(char)
+				continue
+			}
+			endStatement()
+		case isWordChar(char) && (i == 0 || !isWordChar(sql[i-1])):
+			end := i + 1
+			for end < len(sql) && isWordChar(sql[end]) {
+				end++
+			}
+			word := sql[i:end]
+			switch {
+			case strings.EqualFold(word, "BEGIN"), strings.EqualFold(word, "CASE"):
+				depth++
+			case strings.EqualFold(word, "END") && depth > 0:
+				depth--
+			}
+			current.WriteString(word)
+			i = end - 1
+		default:
+			current.WriteByte(char)
+		}
+	}
+	endStatement()
+
+	return statements
+}
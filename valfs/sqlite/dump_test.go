@@ -0,0 +1,99 @@
+package valfs
+
+import (
+	"encoding/json"
+	"testing"
+
+	common "github.com/404wolf/valfs/common"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSplitStatements(t *testing.T) {
+	tests := []struct {
+		name     string
+		sql      string
+		expected []string
+	}{
+		{
+			"simple",
+			"CREATE TABLE a (id INTEGER);\nINSERT INTO a VALUES (1);",
+			[]string{"CREATE TABLE a (id INTEGER)", "INSERT INTO a VALUES (1)"},
+		},
+		{
+			"no trailing semicolon",
+			"SELECT 1;\n\nSELECT 2\n",
+			[]string{"SELECT 1", "SELECT 2"},
+		},
+		{
+			"semicolons in strings and names",
+			`INSERT INTO "a;b" VALUES ('it''s; fine', [c;d], ` + "`e;f`" + `);`,
+			[]string{`INSERT INTO "a;b" VALUES ('it''s; fine', [c;d], ` + "`e;f`" + `)`},
+		},
+		{
+			"comments",
+			"-- first; not a statement\nSELECT 1; /* a; b */ SELECT 2;",
+			[]string{"SELECT 1", "SELECT 2"},
+		},
+		{
+			"trigger",
+			"CREATE TRIGGER t AFTER INSERT ON a BEGIN\n  UPDATE b SET n = n + 1;\n  DELETE FROM c;\nEND;\nSELECT 1;",
+			[]string{
+				"CREATE TRIGGER t AFTER INSERT ON a BEGIN\n  UPDATE b SET n = n + 1;\n  DELETE FROM c;\nEND",
+				"SELECT 1",
+			},
+		},
+		{
+			"trigger with case",
+			"CREATE TRIGGER t AFTER INSERT ON a BEGIN\n  UPDATE b SET n = CASE WHEN new.x THEN 1 ELSE 0 END;\n  DELETE FROM c;\nEND;\nSELECT 1;",
+			[]string{
+				"CREATE TRIGGER t AFTER INSERT ON a BEGIN\n  UPDATE b SET n = CASE WHEN new.x THEN 1 ELSE 0 END;\n  DELETE FROM c;\nEND",
+				"SELECT 1",
+			},
+		},
+		{
+			"transaction",
+			"BEGIN;\nSELECT 1;\nEND;",
+			[]string{"BEGIN", "SELECT 1", "END"},
+		},
+		{
+			"empty",
+			" ;\n-- nothing here\n;",
+			[]string{},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert.Equal(t, test.expected, SplitStatements(test.sql))
+		})
+	}
+}
+
+func TestInsertStatements(t *testing.T) {
+	result := &common.SqliteResult{
+		Columns: []string{"id", "name", "active"},
+		Rows: [][]any{
+			{json.Number("1"), "o'brien", true},
+			{json.Number("2.5"), nil, false},
+		},
+	}
+
+	inserts, err := insertStatements("users", result)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{
+		`INSERT INTO "users" ("id", "name", "active") VALUES (1, 'o''brien', 1)`,
+		`INSERT INTO "users" ("id", "name", "active") VALUES (2.5, NULL, 0)`,
+	}, inserts)
+}
+
+func TestSqlLiteralBlobs(t *testing.T) {
+	blob, err := sqlLiteral([]any{json.Number("0"), json.Number("171"), json.Number("255")})
+	assert.NoError(t, err)
+	assert.Equal(t, "X'00ABFF'", blob)
+
+	_, err = sqlLiteral([]any{json.Number("256")})
+	assert.Error(t, err, "Bytes over 255 should fail")
+
+	_, err = sqlLiteral(map[string]any{"0": json.Number("1")})
+	assert.Error(t, err, "Unknown types should fail instead of being written as strings")
+}
@@ -0,0 +1,147 @@
+package valfs
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+
+	common "github.com/404wolf/valfs/common"
+)
+
+// A way of rendering the rows of a result as text
+type renderer func(result *common.SqliteResult) ([]byte, error)
+
+// The formats that rows can be rendered in, by file extension
+var formats = map[string]renderer{
+	"csv":   renderCSV,
+	"jsonl": renderJSONL,
+	"json":  renderJSON,
+}
+
+// The file extensions of the formats, in the order they are listed
+var FormatExtensions = []string{"csv", "jsonl", "json"}
+
+// RenderResult renders the rows of a result in the format of a file extension
+func RenderResult(result *common.SqliteResult, extension string) ([]byte, error) {
+	render, ok := formats[extension]
+	if !ok {
+		return nil, fmt.Errorf("unknown format %q", extension)
+	}
+	return render(result)
+}
+
+// renderCSV renders rows as CSV, with a header row of the column names
+func renderCSV(result *common.SqliteResult) ([]byte, error) {
+	var buffer bytes.Buffer
+	writer := csv.NewWriter(&buffer)
+
+	if err := writer.Write(result.Columns); err != nil {
+		return nil, err
+	}
+	for _, row := range result.Rows {
+		record := make([]string, len(row))
+		for i, value := range row {
+			if value != nil {
+				record[i] = fmt.Sprint(value)
+			}
+		}
+		if err := writer.Write(record); err != nil {
+			return nil, err
+		}
+	}
+
+	writer.Flush()
+	return buffer.Bytes(), writer.Error()
+}
+
+// renderJSONL renders rows as JSON objects, one per line
+func renderJSONL(result *common.SqliteResult) ([]byte, error) {
+	var buffer bytes.Buffer
+	for _, row := range rowObjects(result) {
+		line, err := json.Marshal(row)
+		if err != nil {
+			return nil, err
+		}
+		buffer.Write(line)
+		buffer.WriteByte('\n')
+	}
+	return buffer.Bytes(), nil
+}
+
+// renderJSON renders rows as an indented JSON array of objects
+func renderJSON(result *common.SqliteResult) ([]byte, error) {
+	rendered, err := json.MarshalIndent(rowObjects(result), "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append(rendered, '\n'), nil
+}
+
+// rowObject is a row as a JSON object, with its keys in column order, which a
+// map wouldn't keep
+type rowObject struct {
+	keys   []string
+	values []any
+}
+
+// MarshalJSON renders the row as an object with its keys in column order
+func (o rowObject) MarshalJSON() ([]byte, error) {
+	var buffer bytes.Buffer
+	buffer.WriteByte('{')
+	for i, key := range o.keys {
+		if i > 0 {
+			buffer.WriteByte(',')
+		}
+		renderedKey, err := json.Marshal(key)
+		if err != nil {
+			return nil, err
+		}
+		renderedValue, err := json.Marshal(o.values[i])
+		if err != nil {
+			return nil, err
+		}
+		buffer.Write(renderedKey)
+		buffer.WriteByte(':')
+		buffer.Write(renderedValue)
+	}
+	buffer.WriteByte('}')
+	return buffer.Bytes(), nil
+}
+
+// rowObjects turns rows into objects keyed by column name
+func rowObjects(result *common.SqliteResult) []rowObject {
+	keys := uniqueKeys(result.Columns)
+	objects := make([]rowObject, len(result.Rows))
+	for i, row := range result.Rows {
+		object := rowObject{keys: keys, values: make([]any, len(keys))}
+		copy(object.values, row)
+		objects[i] = object
+	}
+	return objects
+}
+
+// uniqueKeys makes column names unique, so that columns with the same name,
+// like the ids of two joined tables, don't overwrite each other. Repeats get a
+// suffix, so `id, id` becomes `id, id_2`.
+func uniqueKeys(columns []string) []string {
+	taken := make(map[string]bool, len(columns))
+	for _, column := range columns {
+		taken[column] = true
+	}
+
+	keys := make([]string, len(columns))
+	seen := make(map[string]bool, len(columns))
+	for i, column := range columns {
+		key := column
+		for n := 2; seen[key]; n++ {
+			key = fmt.Sprintf("%s_%d", column, n)
+			if taken[key] {
+				key = column
+			}
+		}
+		seen[key] = true
+		keys[i] = key
+	}
+	return keys
+}
@@ -0,0 +1,75 @@
+package valfs
+
+import (
+	"encoding/json"
+	"testing"
+
+	common "github.com/404wolf/valfs/common"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRenderResult(t *testing.T) {
+	result := &common.SqliteResult{
+		Columns: []string{"id", "name"},
+		Rows: [][]any{
+			{json.Number("1"), "wolf"},
+			{json.Number("12345678901234567890"), nil},
+			{json.Number("3"), "comma, \"quote\""},
+		},
+	}
+
+	tests := []struct {
+		extension string
+		expected  string
+	}{
+		{
+			"csv",
+			"id,name\n1,wolf\n12345678901234567890,\n3,\"comma, \"\"quote\"\"\"\n",
+		},
+		{
+			"jsonl",
+			`{"id":1,"name":"wolf"}` + "\n" +
+				`{"id":12345678901234567890,"name":null}` + "\n" +
+				`{"id":3,"name":"comma, \"quote\""}` + "\n",
+		},
+		{
+			"json",
+			"[\n  {\n    \"id\": 1,\n    \"name\": \"wolf\"\n  },\n" +
+				"  {\n    \"id\": 12345678901234567890,\n    \"name\": null\n  },\n" +
+				"  {\n    \"id\": 3,\n    \"name\": \"comma, \\\"quote\\\"\"\n  }\n]\n",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.extension, func(t *testing.T) {
+			rendered, err := RenderResult(result, test.extension)
+			assert.NoError(t, err)
+			assert.Equal(t, test.expected, string(rendered))
+		})
+	}
+
+	_, err := RenderResult(result, "xml")
+	assert.Error(t, err)
+}
+
+func TestRenderResultColumns(t *testing.T) {
+	// Keys keep the order of the columns, and repeated column names are made
+	// unique instead of overwriting each other
+	result := &common.SqliteResult{
+		Columns: []string{"name", "id", "id", "id_2"},
+		Rows:    [][]any{{"wolf", json.Number("1"), json.Number("2"), json.Number("3")}},
+	}
+
+	rendered, err := RenderResult(result, "jsonl")
+	assert.NoError(t, err)
+	assert.Equal(t, `{"name":"wolf","id":1,"id_3":2,"id_2":3}`+"\n", string(rendered))
+
+	rendered, err = RenderResult(result, "json")
+	assert.NoError(t, err)
+	assert.Equal(t, "[\n  {\n    \"name\": \"wolf\",\n    \"id\": 1,\n    \"id_3\": 2,\n    \"id_2\": 3\n  }\n]\n", string(rendered))
+}
+
+func TestQuoteIdentifier(t *testing.T) {
+	assert.Equal(t, `"users"`, QuoteIdentifier("users"))
+	assert.Equal(t, `"say ""hi"""`, QuoteIdentifier(`say "hi"`))
+}
@@ -0,0 +1,136 @@
+package valfs
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+
+	common "github.com/404wolf/valfs/common"
+)
+
+// The table that the versions of applied migrations are recorded in
+const MigrationsTable = "_valfs_migrations"
+
+// Matches the file names of migrations, which start with their version, like
+// 001_create_users.sql
+var migrationFilenameRe = regexp.MustCompile(`^(\d+)[^/]*\.sql$`)
+
+// Migration is a numbered SQL file that changes the database
+type Migration struct {
+	Version int64
+	Name    string // File name of the migration
+	Path    string
+}
+
+// ListMigrations finds the migrations in a directory, sorted by version.
+// Files that don't start with a number, or aren't .sql files, are skipped.
+func ListMigrations(dir string) ([]Migration, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	migrations := []Migration{}
+	versions := make(map[int64]string)
+	for _, entry := range entries {
+		matches := migrationFilenameRe.FindStringSubmatch(entry.Name())
+		if entry.IsDir() || matches == nil {
+			continue
+		}
+
+		version, err := strconv.ParseInt(matches[1], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid version in %s: %w", entry.Name(), err)
+		}
+		if other, exists := versions[version]; exists {
+			return nil, fmt.Errorf("migrations %s and %s have the same version %d", other, entry.Name(), version)
+		}
+		versions[version] = entry.Name()
+
+		migrations = append(migrations, Migration{
+			Version: version,
+			Name:    entry.Name(),
+			Path:    filepath.Join(dir, entry.Name()),
+		})
+	}
+
+	slices.SortFunc(migrations, func(a, b Migration) int {
+		return cmp.Compare(a.Version, b.Version)
+	})
+	return migrations, nil
+}
+
+// Migrate applies the migrations in a directory that haven't been applied yet,
+// in order of their versions. Each migration runs in its own transaction along
+// with recording its version, so a failed migration changes nothing, and the
+// ones before it stay applied. Returns the migrations that were applied.
+func Migrate(ctx context.Context, apiClient *common.APIClient, dir string) ([]Migration, error) {
+	migrations, err := ListMigrations(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	applied, err := appliedVersions(ctx, apiClient)
+	if err != nil {
+		return nil, err
+	}
+
+	newlyApplied := []Migration{}
+	for _, migration := range migrations {
+		if applied[migration.Version] {
+			continue
+		}
+
+		contents, err := os.ReadFile(migration.Path)
+		if err != nil {
+			return newlyApplied, err
+		}
+
+		statements := append(SplitStatements(string(contents)), fmt.Sprintf(
+			"INSERT INTO %s (version, name) VALUES (%d, %s)",
+			QuoteIdentifier(MigrationsTable),
+			migration.Version,
+			stringLiteral(migration.Name),
+		))
+		if _, err := apiClient.SqliteBatch(ctx, statements, common.SqliteWrite); err != nil {
+			return newlyApplied, fmt.Errorf("applying migration %s: %w", migration.Name, err)
+		}
+
+		common.Logger.Infof("Applied migration %s", migration.Name)
+		newlyApplied = append(newlyApplied, migration)
+	}
+
+	return newlyApplied, nil
+}
+
+// appliedVersions gets the versions of the migrations that were already
+// applied, creating the table that tracks them if it doesn't exist yet
+func appliedVersions(ctx context.Context, apiClient *common.APIClient) (map[int64]bool, error) {
+	_, err := apiClient.SqliteExecute(ctx, fmt.Sprintf(
+		"CREATE TABLE IF NOT EXISTS %s (version INTEGER PRIMARY KEY, name TEXT NOT NULL, applied_at TEXT NOT NULL DEFAULT CURRENT_TIMESTAMP)",
+		QuoteIdentifier(MigrationsTable),
+	))
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := apiClient.SqliteExecute(ctx, "SELECT version FROM "+QuoteIdentifier(MigrationsTable))
+	if err != nil {
+		return nil, err
+	}
+
+	applied := make(map[int64]bool)
+	for _, row := range result.Rows {
+		version, err := strconv.ParseInt(fmt.Sprint(row[0]), 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid migration version %v: %w", row[0], err)
+		}
+		applied[version] = true
+	}
+	return applied, nil
+}
@@ -0,0 +1,33 @@
+package valfs
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestListMigrations(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"010_add_index.sql", "2_add_email.sql", "001_create_users.sql", "notes.md", "seed.sql"} {
+		assert.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte("SELECT 1;"), 0644))
+	}
+
+	migrations, err := ListMigrations(dir)
+	assert.NoError(t, err)
+
+	names := []string{}
+	versions := []int64{}
+	for _, migration := range migrations {
+		names = append(names, migration.Name)
+		versions = append(versions, migration.Version)
+	}
+	assert.Equal(t, []string{"001_create_users.sql", "2_add_email.sql", "010_add_index.sql"}, names)
+	assert.Equal(t, []int64{1, 2, 10}, versions)
+
+	// Two migrations with the same version are ambiguous
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "0002_other.sql"), []byte("SELECT 1;"), 0644))
+	_, err = ListMigrations(dir)
+	assert.Error(t, err)
+}
@@ -0,0 +1,171 @@
+package valfs
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"syscall"
+
+	common "github.com/404wolf/valfs/common"
+	memfile "github.com/404wolf/valfs/valfs/memfile"
+	"github.com/hanwen/go-fuse/v2/fs"
+	"github.com/hanwen/go-fuse/v2/fuse"
+)
+
+// The formats that the results of queries are written in
+var resultExtensions = []string{"json", "csv"}
+
+// QueriesDir is a directory where writing a .sql file runs it against val
+// town's SQLite database. The rows, or the error, show up next to it in
+// name.result.json and name.result.csv.
+type QueriesDir struct {
+	fs.Inode
+
+	client *common.Client
+}
+
+var _ = (fs.NodeCreater)((*QueriesDir)(nil))
+var _ = (fs.NodeUnlinker)((*QueriesDir)(nil))
+var _ = (fs.NodeRenamer)((*QueriesDir)(nil))
+
+// NewQueriesDir creates a directory for running SQL files
+func NewQueriesDir(ctx context.Context, parent *fs.Inode, client *common.Client) *QueriesDir {
+	queriesDir := &QueriesDir{client: client}
+	parent.NewPersistentInode(ctx, queriesDir, fs.StableAttr{Mode: syscall.S_IFDIR | 0755})
+	return queriesDir
+}
+
+// Create makes a new file. Files that aren't .sql files are allowed too, so
+// that editors can make their swap and backup files.
+func (c *QueriesDir) Create(
+	ctx context.Context,
+	name string,
+	flags uint32,
+	mode uint32,
+	entryOut *fuse.EntryOut,
+) (inode *fs.Inode, fh fs.FileHandle, fuseFlags uint32, code syscall.Errno) {
+	if isResultFile(name) {
+		return nil, nil, 0, syscall.EACCES
+	}
+
+	queryFile := memfile.NewMemoryFile(c.runQuery)
+	inode = c.NewPersistentInode(ctx, queryFile, fs.StableAttr{Mode: syscall.S_IFREG})
+	entryOut.Attr.Mode = 0644
+	return inode, nil, 0, syscall.F_OK
+}
+
+// Unlink removes a query or result file
+func (c *QueriesDir) Unlink(ctx context.Context, name string) syscall.Errno {
+	return syscall.F_OK
+}
+
+// Rename moves a file within the directory, and runs it if it becomes a .sql
+// file, since many editors save by renaming a new file over the old one
+func (c *QueriesDir) Rename(
+	ctx context.Context,
+	oldName string,
+	newParent fs.InodeEmbedder,
+	newName string,
+	flags uint32,
+) syscall.Errno {
+	if newParent.EmbeddedInode() != &c.Inode {
+		return syscall.EXDEV
+	}
+	if isResultFile(oldName) || isResultFile(newName) {
+		return syscall.EACCES
+	}
+
+	child := c.GetChild(oldName)
+	if child == nil {
+		return syscall.ENOENT
+	}
+	if queryFile, ok := child.Operations().(*memfile.MemoryFile); ok {
+		queryFile.Save(ctx, newName)
+	}
+
+	return syscall.F_OK
+}
+
+// writeResult sets the contents of a result file, creating it if needed
+func (c *QueriesDir) writeResult(ctx context.Context, name string, data []byte) {
+	if child := c.GetChild(name); child != nil {
+		if resultFile, ok := child.Operations().(*memfile.MemoryFile); ok {
+			resultFile.Set(data)
+			return
+		}
+	}
+
+	resultFile := memfile.NewReadOnlyMemoryFile(data)
+	c.NewPersistentInode(ctx, resultFile, fs.StableAttr{Mode: syscall.S_IFREG})
+	c.AddChild(name, &resultFile.Inode, true)
+}
+
+// isResultFile checks whether a file name is the name of a result file
+func isResultFile(name string) bool {
+	for _, extension := range resultExtensions {
+		if strings.HasSuffix(name, ".result."+extension) {
+			return true
+		}
+	}
+	return false
+}
+
+// runQuery runs a query file against the database if its name is a .sql
+// file, and writes the rows, or the error, to the result files of that name.
+// Query files can be run again, so they are never made read only.
+func (c *QueriesDir) runQuery(
+	ctx context.Context,
+	file *memfile.MemoryFile,
+	name string,
+	data []byte,
+) (readOnly bool, errno syscall.Errno) {
+	statement := string(data)
+	if !strings.HasSuffix(name, ".sql") || strings.TrimSpace(statement) == "" {
+		return false, syscall.F_OK
+	}
+
+	common.Logger.Infof("Running SQL query %s", name)
+	result, err := c.client.APIClient.SqliteExecute(ctx, statement)
+
+	if err != nil {
+		common.Logger.Errorf("Error running SQL query %s: %v", name, err)
+	}
+
+	baseName := strings.TrimSuffix(name, ".sql")
+	for _, extension := range resultExtensions {
+		rendered, renderErr := []byte(nil), err
+		if renderErr == nil {
+			rendered, renderErr = RenderResult(withRowsAffected(result), extension)
+		}
+		if renderErr != nil {
+			rendered = renderError(renderErr, extension)
+		}
+		c.writeResult(ctx, baseName+".result."+extension, rendered)
+	}
+	return false, syscall.F_OK
+}
+
+// renderError renders the error of a query in place of its rows, as an object
+// with an error field in JSON, so that the result is still valid JSON, and as
+// the message in anything else
+func renderError(err error, extension string) []byte {
+	if extension == "json" {
+		rendered, marshalErr := json.Marshal(map[string]string{"error": err.Error()})
+		if marshalErr == nil {
+			return append(rendered, '\n')
+		}
+	}
+	return []byte(err.Error() + "\n")
+}
+
+// withRowsAffected gives statements that don't return rows, like inserts, a
+// single row with the number of rows that they changed
+func withRowsAffected(result *common.SqliteResult) *common.SqliteResult {
+	if len(result.Columns) > 0 {
+		return result
+	}
+	return &common.SqliteResult{
+		Columns: []string{"rowsAffected"},
+		Rows:    [][]any{{result.RowsAffected}},
+	}
+}
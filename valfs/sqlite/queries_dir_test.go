@@ -0,0 +1,32 @@
+package valfs
+
+import (
+	"errors"
+	"testing"
+
+	common "github.com/404wolf/valfs/common"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIsResultFile(t *testing.T) {
+	assert.True(t, isResultFile("old.result.json"))
+	assert.True(t, isResultFile("old.result.csv"))
+	assert.False(t, isResultFile("old.sql"))
+	assert.False(t, isResultFile("result.json"))
+}
+
+func TestWithRowsAffected(t *testing.T) {
+	rows := &common.SqliteResult{Columns: []string{"id"}, Rows: [][]any{{1}}}
+	assert.Same(t, rows, withRowsAffected(rows))
+
+	insert := &common.SqliteResult{RowsAffected: 3}
+	rendered, err := RenderResult(withRowsAffected(insert), "csv")
+	assert.NoError(t, err)
+	assert.Equal(t, "rowsAffected\n3\n", string(rendered))
+}
+
+func TestRenderError(t *testing.T) {
+	err := errors.New(`no such table: "users"`)
+	assert.Equal(t, `{"error":"no such table: \"users\""}`+"\n", string(renderError(err, "json")))
+	assert.Equal(t, `no such table: "users"`+"\n", string(renderError(err, "csv")))
+}
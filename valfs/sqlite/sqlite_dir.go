@@ -0,0 +1,188 @@
+package valfs
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	common "github.com/404wolf/valfs/common"
+	memfile "github.com/404wolf/valfs/valfs/memfile"
+	"github.com/hanwen/go-fuse/v2/fs"
+	"github.com/hanwen/go-fuse/v2/fuse"
+)
+
+// How long the listed tables are fresh for
+const TablesTTL = 10 * time.Second
+
+// The name of the file with the schema of the database
+const SchemaFilename = "schema.sql"
+
+// The name of the directory for running queries
+const QueriesDirname = "queries"
+
+// Lists the tables made by the user, leaving out SQLite's internal ones
+const listTablesSQL = `SELECT name FROM sqlite_master WHERE type = 'table' AND name NOT LIKE 'sqlite_%' ORDER BY name`
+
+// Gets the statements that create everything in the database
+const schemaSQL = `SELECT sql FROM sqlite_master WHERE sql IS NOT NULL AND name NOT LIKE 'sqlite_%' ORDER BY type = 'table' DESC, name`
+
+// SqliteDir is a directory with a file for each table in val town's SQLite
+// database, in each format, like users.csv and users.jsonl. The rows are
+// queried when a table's file is opened. It also has the schema of the
+// database, and a directory for running queries.
+type SqliteDir struct {
+	fs.Inode
+
+	client *common.Client
+
+	mutex       sync.Mutex
+	refreshedAt time.Time       // When the tables were last listed
+	tables      map[string]bool // The tables that have files
+}
+
+var _ = (fs.NodeLookuper)((*SqliteDir)(nil))
+var _ = (fs.NodeOpendirer)((*SqliteDir)(nil))
+
+// NewSqliteDir creates a directory of the tables in val town's SQLite database
+func NewSqliteDir(ctx context.Context, parent *fs.Inode, client *common.Client) *SqliteDir {
+	sqliteDir := &SqliteDir{
+		client: client,
+		tables: make(map[string]bool),
+	}
+	parent.NewPersistentInode(ctx, sqliteDir, fs.StableAttr{Mode: syscall.S_IFDIR | 0555})
+
+	schemaFile := memfile.NewRenderedFile(sqliteDir.renderSchema)
+	sqliteDir.NewPersistentInode(ctx, schemaFile, fs.StableAttr{Mode: syscall.S_IFREG})
+	sqliteDir.AddChild(SchemaFilename, &schemaFile.Inode, true)
+
+	queriesDir := NewQueriesDir(ctx, &sqliteDir.Inode, client)
+	sqliteDir.AddChild(QueriesDirname, &queriesDir.Inode, true)
+
+	return sqliteDir
+}
+
+// Opendir lists the tables again before the directory is read, if they are
+// stale
+func (c *SqliteDir) Opendir(ctx context.Context) syscall.Errno {
+	return c.refreshIfStale(ctx)
+}
+
+// Lookup finds a file in the directory, listing the tables again first if they
+// are stale
+func (c *SqliteDir) Lookup(
+	ctx context.Context,
+	name string,
+	out *fuse.EntryOut,
+) (*fs.Inode, syscall.Errno) {
+	if errno := c.refreshIfStale(ctx); errno != syscall.F_OK {
+		return nil, errno
+	}
+
+	child := c.GetChild(name)
+	if child == nil {
+		return nil, syscall.ENOENT
+	}
+
+	if getattrer, ok := child.Operations().(fs.NodeGetattrer); ok {
+		attrOut := fuse.AttrOut{}
+		if errno := getattrer.Getattr(ctx, nil, &attrOut); errno == syscall.F_OK {
+			out.Attr = attrOut.Attr
+		}
+	}
+
+	return child, syscall.F_OK
+}
+
+// refreshIfStale lists the tables again if they were listed longer than
+// TablesTTL ago
+func (c *SqliteDir) refreshIfStale(ctx context.Context) syscall.Errno {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if time.Since(c.refreshedAt) < TablesTTL {
+		return syscall.F_OK
+	}
+
+	if err := c.refresh(ctx); err != nil {
+		common.Logger.Errorf("Error listing SQLite tables: %v", err)
+		return common.ToErrno(err)
+	}
+
+	return syscall.F_OK
+}
+
+// refresh lists the tables and brings the table files in line with them. The
+// mutex must be held.
+func (c *SqliteDir) refresh(ctx context.Context) error {
+	result, err := c.client.APIClient.SqliteExecute(ctx, listTablesSQL)
+	if err != nil {
+		return err
+	}
+
+	newTables := make(map[string]bool)
+	for _, row := range result.Rows {
+		table, ok := row[0].(string)
+		// Tables with slashes can't be file names
+		if !ok || strings.Contains(table, "/") {
+			continue
+		}
+		newTables[table] = true
+
+		if c.tables[table] {
+			continue
+		}
+		for _, extension := range FormatExtensions {
+			tableFile := memfile.NewRenderedFile(c.tableRenderer(table, extension))
+			c.NewPersistentInode(ctx, tableFile, fs.StableAttr{Mode: syscall.S_IFREG})
+			c.AddChild(table+"."+extension, &tableFile.Inode, true)
+		}
+	}
+
+	for table := range c.tables {
+		if !newTables[table] {
+			for _, extension := range FormatExtensions {
+				c.RmChild(table + "." + extension)
+			}
+		}
+	}
+
+	c.tables = newTables
+	c.refreshedAt = time.Now()
+
+	common.Logger.Infof("Listed %d SQLite tables", len(newTables))
+	return nil
+}
+
+// tableRenderer renders all the rows of a table in the format of an extension
+func (c *SqliteDir) tableRenderer(table string, extension string) func(ctx context.Context) ([]byte, error) {
+	return func(ctx context.Context) ([]byte, error) {
+		result, err := c.client.APIClient.SqliteExecute(ctx, "SELECT * FROM "+QuoteIdentifier(table))
+		if err != nil {
+			return nil, err
+		}
+		return RenderResult(result, extension)
+	}
+}
+
+// renderSchema renders the statements that create the tables, indexes, views
+// and triggers of the database
+func (c *SqliteDir) renderSchema(ctx context.Context) ([]byte, error) {
+	result, err := c.client.APIClient.SqliteExecute(ctx, schemaSQL)
+	if err != nil {
+		return nil, err
+	}
+
+	var schema strings.Builder
+	for _, row := range result.Rows {
+		fmt.Fprintf(&schema, "%s;\n\n", row[0])
+	}
+	return []byte(strings.TrimSuffix(schema.String(), "\n")), nil
+}
+
+// QuoteIdentifier quotes the name of a table or column for use in SQL
+func QuoteIdentifier(name string) string {
+	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+}
@@ -13,6 +13,8 @@ import (
 
 	common "github.com/404wolf/valfs/common"
 	editor "github.com/404wolf/valfs/valfs/editor"
+	outbox "github.com/404wolf/valfs/valfs/outbox"
+	sqlite "github.com/404wolf/valfs/valfs/sqlite"
 	vals "github.com/404wolf/valfs/valfs/vals"
 )
 
@@ -20,6 +22,7 @@ import (
 type ValFS struct {
 	fs.Inode
 	client           *common.Client
+	valsDir          vals.ValsContainer
 	denoCacheLastRun time.Time
 	denoCacheMutex   sync.Mutex
 }
@@ -31,8 +34,67 @@ func NewValFS(client *common.Client) *ValFS {
 
 func (c *ValFS) AddValsDir(ctx context.Context) {
 	common.Logger.Info("Adding vals directory to valfs")
-	valsDir := vals.NewValsDir(&c.Inode, c.client, ctx)
-	c.AddChild("vals", valsDir.GetInode(), true)
+	c.valsDir = vals.NewValsDir(&c.Inode, c.client, ctx)
+	c.AddChild("vals", c.valsDir.GetInode(), true)
+}
+
+// Add the by-privacy and by-type directories, which hold symlinks to the val
+// files in the vals directory
+func (c *ValFS) AddViews(ctx context.Context) {
+	common.Logger.Info("Adding views of vals to valfs")
+	privacyView := vals.NewPrivacyView(ctx, &c.Inode, c.valsDir)
+	c.AddChild("by-privacy", &privacyView.Inode, true)
+	typeView := vals.NewTypeView(ctx, &c.Inode, c.valsDir)
+	c.AddChild("by-type", &typeView.Inode, true)
+}
+
+// Add the by-id directory, where vals can be looked up by their id
+func (c *ValFS) AddByIdDir(ctx context.Context) {
+	common.Logger.Info("Adding by-id directory to valfs")
+	byIdDir := vals.NewValsByIdDir(ctx, &c.Inode, c.client, c.valsDir)
+	c.AddChild("by-id", &byIdDir.Inode, true)
+}
+
+// Add the users directory, with the public vals of other users
+func (c *ValFS) AddUsersDir(ctx context.Context) {
+	common.Logger.Info("Adding users directory to valfs")
+	usersDir := vals.NewUsersDir(ctx, &c.Inode, c.client)
+	c.AddChild("users", &usersDir.Inode, true)
+}
+
+// Add the directory that searches val town for vals
+func (c *ValFS) AddSearchDir(ctx context.Context) {
+	common.Logger.Info("Adding search directory to valfs")
+	searchDir := vals.NewSearchDir(ctx, &c.Inode, c.client)
+	c.AddChild("search", &searchDir.Inode, true)
+}
+
+// Add the directory with the vals that the user has liked
+func (c *ValFS) AddLikedDir(ctx context.Context) {
+	common.Logger.Info("Adding liked directory to valfs")
+	likedDir := vals.NewLikedDir(ctx, &c.Inode, c.client)
+	c.AddChild("liked", &likedDir.Inode, true)
+}
+
+// Add the directory with the tables of the user's SQLite database
+func (c *ValFS) AddSqliteDir(ctx context.Context) {
+	common.Logger.Info("Adding sqlite directory to valfs")
+	sqliteDir := sqlite.NewSqliteDir(ctx, &c.Inode, c.client)
+	c.AddChild("sqlite", &sqliteDir.Inode, true)
+}
+
+// Add the directory where emails written to it are sent to the user
+func (c *ValFS) AddOutboxDir(ctx context.Context) {
+	common.Logger.Info("Adding outbox directory to valfs")
+	outboxDir := outbox.NewOutboxDir(ctx, &c.Inode, c.client)
+	c.AddChild("outbox", &outboxDir.Inode, true)
+}
+
+// Add the me.json file with the profile of the user and counts of their vals
+func (c *ValFS) AddMeFile(ctx context.Context) {
+	common.Logger.Info("Adding me.json to valfs")
+	meFile := vals.NewMeFile(ctx, &c.Inode, c.client, c.valsDir)
+	c.AddChild("me.json", &meFile.Inode, true)
 }
 
 // Add the deno.json file which provides the user context about how to run and
@@ -43,6 +105,13 @@ func (c *ValFS) AddDenoJSON(ctx context.Context) {
 	c.AddChild("deno.json", denoJsonInode, false)
 }
 
+// Push writes that are still being held back to val town before exiting
+func (c *ValFS) flushWrites() {
+	if c.valsDir != nil {
+		c.valsDir.FlushWrites(context.Background())
+	}
+}
+
 // Mount the filesystem
 func (c *ValFS) Mount(doneSettingUp func()) error {
 	common.Logger.Info("Mounting ValFS file system at ", c.client.Config.MountPoint)
@@ -56,6 +125,46 @@ func (c *ValFS) Mount(doneSettingUp func()) error {
 			// Add the folder with all the vals
 			if c.client.Config.EnableValsDirectory {
 				c.AddValsDir(ctx)
+
+				// Add the views of the vals
+				if c.client.Config.EnableViews {
+					c.AddViews(ctx)
+				}
+			}
+
+			// Add the folder to look up vals by id
+			if c.client.Config.EnableByIdDirectory {
+				c.AddByIdDir(ctx)
+			}
+
+			// Add the folder with other users' vals
+			if c.client.Config.EnableUsersDirectory {
+				c.AddUsersDir(ctx)
+			}
+
+			// Add the folder to search for vals
+			if c.client.Config.EnableSearchDirectory {
+				c.AddSearchDir(ctx)
+			}
+
+			// Add the folder with liked vals
+			if c.client.Config.EnableLikedDirectory {
+				c.AddLikedDir(ctx)
+			}
+
+			// Add the folder with SQLite tables
+			if c.client.Config.EnableSqliteDirectory {
+				c.AddSqliteDir(ctx)
+			}
+
+			// Add the folder for sending emails
+			if c.client.Config.EnableOutboxDirectory {
+				c.AddOutboxDir(ctx)
+			}
+
+			// Add the file with the user's profile
+			if c.client.Config.EnableMeFile {
+				c.AddMeFile(ctx)
 			}
 
 			// Add the deno.json file
@@ -78,6 +187,7 @@ func (c *ValFS) Mount(doneSettingUp func()) error {
 		go func() {
 			<-signalChan
 			common.Logger.Info("Received interrupt signal. Unmounting...")
+			c.flushWrites()
 			err := server.Unmount()
 			if err != nil {
 				common.Logger.Error("Error unmounting", "error", err)
@@ -87,6 +197,7 @@ func (c *ValFS) Mount(doneSettingUp func()) error {
 
 		defer func() {
 			common.Logger.Info("Unmounting valfs @ %s", c.client.Config.MountPoint)
+			c.flushWrites()
 			err := server.Unmount()
 			if err != nil {
 				common.Logger.Error("Error unmounting", "error", err)
@@ -0,0 +1,199 @@
+package valfs
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+
+	common "github.com/404wolf/valfs/common"
+)
+
+// The extension of draft files in the drafts directory
+const draftExtension = "." + ValExtension
+
+// The extension of the files with the options that drafts were written with
+const draftOptionsExtension = ".json"
+
+// The extended attribute that marks val files with unpublished drafts
+const DraftXattr = "user.valfs.draft"
+
+// DraftStore keeps local drafts of vals on disk, keyed by val id. It lives on
+// disk so that the publish and discard commands can work with the drafts of a
+// running mount.
+type DraftStore struct {
+	Dir string // Directory the drafts are stored in
+}
+
+// DraftOptions are the settings of the mount that a draft was written with. A
+// draft only has the frontmatter fields that the mount showed, so it is
+// published with the same settings it was written with.
+type DraftOptions struct {
+	StaticMeta     bool     `json:"staticMeta"`
+	ExecutableVals bool     `json:"executableVals"`
+	ReadmeFiles    bool     `json:"readmeFiles"`
+	Fields         []string `json:"fields"` // Frontmatter fields, or nil for all
+}
+
+// DraftOptionsOf returns the draft options of a mount's config
+func DraftOptionsOf(config common.ValfsConfig) DraftOptions {
+	return DraftOptions{
+		StaticMeta:     config.StaticMeta,
+		ExecutableVals: config.ExecutableVals,
+		ReadmeFiles:    config.ReadmeFiles,
+		Fields:         config.FrontmatterFields,
+	}
+}
+
+// ValPackage makes a package of a val that reads and writes val files with
+// these options
+func (o DraftOptions) ValPackage(val Val) ValPackage {
+	valPackage := NewValPackage(val, o.StaticMeta, o.ExecutableVals)
+	valPackage.ReadmeFiles = o.ReadmeFiles
+	valPackage.Fields = o.Fields
+	return valPackage
+}
+
+// NewDraftStore creates a draft store that keeps drafts in a directory
+func NewDraftStore(dir string) *DraftStore {
+	return &DraftStore{Dir: dir}
+}
+
+// DefaultDraftsDir returns where drafts are stored by default
+func DefaultDraftsDir() string {
+	return filepath.Join(stateDir(), "drafts")
+}
+
+// stateDir returns the directory that valfs keeps local state in
+func stateDir() string {
+	stateHome := os.Getenv("XDG_STATE_HOME")
+	if stateHome == "" {
+		homeDir, err := os.UserHomeDir()
+		if err != nil {
+			return filepath.Join(os.TempDir(), "valfs")
+		}
+		stateHome = filepath.Join(homeDir, ".local", "state")
+	}
+	return filepath.Join(stateHome, "valfs")
+}
+
+// path returns the path of the draft of a val
+func (d *DraftStore) path(valId string) string {
+	return filepath.Join(d.Dir, valId+draftExtension)
+}
+
+// optionsPath returns the path of the options the draft of a val was written
+// with
+func (d *DraftStore) optionsPath(valId string) string {
+	return filepath.Join(d.Dir, valId+draftOptionsExtension)
+}
+
+// Get returns the draft of a val, and whether there is one
+func (d *DraftStore) Get(valId string) (string, bool) {
+	contents, err := os.ReadFile(d.path(valId))
+	if err != nil {
+		return "", false
+	}
+	return string(contents), true
+}
+
+// Has returns whether there is a draft of a val
+func (d *DraftStore) Has(valId string) bool {
+	_, err := os.Stat(d.path(valId))
+	return err == nil
+}
+
+// Options returns the options the draft of a val was written with
+func (d *DraftStore) Options(valId string) (DraftOptions, error) {
+	options := DraftOptions{}
+	contents, err := os.ReadFile(d.optionsPath(valId))
+	if err != nil {
+		return options, err
+	}
+	err = json.Unmarshal(contents, &options)
+	return options, err
+}
+
+// Put saves the draft of a val along with the options it was written with,
+// replacing any existing draft
+func (d *DraftStore) Put(valId string, contents string, options DraftOptions) error {
+	if err := os.MkdirAll(d.Dir, 0o700); err != nil {
+		return err
+	}
+
+	encodedOptions, err := json.Marshal(options)
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(d.optionsPath(valId), encodedOptions, 0o600); err != nil {
+		return err
+	}
+
+	return os.WriteFile(d.path(valId), []byte(contents), 0o600)
+}
+
+// Delete drops the draft of a val, if there is one
+func (d *DraftStore) Delete(valId string) error {
+	err := os.Remove(d.optionsPath(valId))
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+
+	err = os.Remove(d.path(valId))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	return err
+}
+
+// List returns the ids of all the vals that have drafts
+func (d *DraftStore) List() ([]string, error) {
+	entries, err := os.ReadDir(d.Dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	valIds := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		if valId, ok := strings.CutSuffix(entry.Name(), draftExtension); ok {
+			valIds = append(valIds, valId)
+		}
+	}
+	return valIds, nil
+}
+
+// PublishDraft pushes the draft of a val to val town, and then drops it
+func (d *DraftStore) PublishDraft(ctx context.Context, val Val) error {
+	contents, ok := d.Get(val.GetId())
+	if !ok {
+		return errors.New("val has no draft")
+	}
+
+	err := val.Load(ctx)
+	if err != nil {
+		return err
+	}
+
+	options, err := d.Options(val.GetId())
+	if err != nil {
+		return err
+	}
+
+	valPackage := options.ValPackage(val)
+	err = valPackage.UpdateVal(contents)
+	if err != nil {
+		return err
+	}
+
+	err = val.Update(ctx)
+	if err != nil {
+		return err
+	}
+
+	common.Logger.Infof("Published draft of val %s", val.GetId())
+	return d.Delete(val.GetId())
+}
@@ -0,0 +1,31 @@
+package valfs_test
+
+import (
+	"testing"
+
+	vals "github.com/404wolf/valfs/valfs/vals"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDraftStoreOptions(t *testing.T) {
+	drafts := vals.NewDraftStore(t.TempDir())
+	options := vals.DraftOptions{ReadmeFiles: true, Fields: []string{"id", "privacy"}}
+
+	require.NoError(t, drafts.Put("val1", "console.log(1)", options))
+	contents, ok := drafts.Get("val1")
+	assert.True(t, ok)
+	assert.Equal(t, "console.log(1)", contents)
+
+	stored, err := drafts.Options("val1")
+	require.NoError(t, err)
+	assert.Equal(t, options, stored, "Drafts should keep the options they were written with")
+
+	require.NoError(t, drafts.Put("val2", "console.log(2)", vals.DraftOptions{}))
+
+	require.NoError(t, drafts.Delete("val1"))
+	assert.False(t, drafts.Has("val1"))
+	valIds, err := drafts.List()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"val2"}, valIds, "Options files aren't drafts")
+}
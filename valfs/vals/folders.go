@@ -0,0 +1,166 @@
+package valfs
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// FolderStore keeps track of which folder each val is in. Val town has no way
+// to group vals, so the folders only exist locally, on disk.
+type FolderStore struct {
+	Path string // File the folders are stored in
+
+	mutex   sync.Mutex
+	folders folderStoreData
+}
+
+// folderStoreData is what a folder store saves to disk
+type folderStoreData struct {
+	Dirs []string          `json:"dirs"` // All the folders, including empty ones
+	Vals map[string]string `json:"vals"` // The folder of each val, by val id
+}
+
+// NewFolderStore loads the folders stored in a file. A missing file is the
+// same as there being no folders.
+func NewFolderStore(path string) (*FolderStore, error) {
+	store := &FolderStore{
+		Path:    path,
+		folders: folderStoreData{Vals: make(map[string]string)},
+	}
+
+	contents, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return store, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	err = json.Unmarshal(contents, &store.folders)
+	if err != nil {
+		return nil, err
+	}
+	if store.folders.Vals == nil {
+		store.folders.Vals = make(map[string]string)
+	}
+
+	return store, nil
+}
+
+// DefaultFoldersFile returns where folders are stored by default
+func DefaultFoldersFile() string {
+	return filepath.Join(stateDir(), "folders.json")
+}
+
+// Dirs returns all the folders, with parents before their children
+func (s *FolderStore) Dirs() []string {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	dirs := slices.Clone(s.folders.Dirs)
+	slices.Sort(dirs)
+	return dirs
+}
+
+// Folder returns the folder that a val is in, or "" if it is not in a folder
+func (s *FolderStore) Folder(valId string) string {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.folders.Vals[valId]
+}
+
+// SetFolder moves a val into a folder, or out of all folders if folder is ""
+func (s *FolderStore) SetFolder(valId string, folder string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if folder == "" {
+		delete(s.folders.Vals, valId)
+	} else {
+		s.addDir(folder)
+		s.folders.Vals[valId] = folder
+	}
+	return s.save()
+}
+
+// RemoveVal forgets the folder of a val
+func (s *FolderStore) RemoveVal(valId string) error {
+	return s.SetFolder(valId, "")
+}
+
+// AddDir adds a folder, along with its parents
+func (s *FolderStore) AddDir(dir string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.addDir(dir)
+	return s.save()
+}
+
+// addDir adds a folder and its parents without saving
+func (s *FolderStore) addDir(dir string) {
+	for ; dir != "." && dir != ""; dir = path.Dir(dir) {
+		if !slices.Contains(s.folders.Dirs, dir) {
+			s.folders.Dirs = append(s.folders.Dirs, dir)
+		}
+	}
+}
+
+// RemoveDir removes a folder. Any vals or folders still in it are moved out of
+// all folders.
+func (s *FolderStore) RemoveDir(dir string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.folders.Dirs = slices.DeleteFunc(s.folders.Dirs, func(d string) bool {
+		return isInDir(d, dir)
+	})
+	for valId, folder := range s.folders.Vals {
+		if isInDir(folder, dir) {
+			delete(s.folders.Vals, valId)
+		}
+	}
+	return s.save()
+}
+
+// RenameDir moves a folder, along with everything in it
+func (s *FolderStore) RenameDir(oldDir string, newDir string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	for i, dir := range s.folders.Dirs {
+		if isInDir(dir, oldDir) {
+			s.folders.Dirs[i] = newDir + strings.TrimPrefix(dir, oldDir)
+		}
+	}
+	for valId, folder := range s.folders.Vals {
+		if isInDir(folder, oldDir) {
+			s.folders.Vals[valId] = newDir + strings.TrimPrefix(folder, oldDir)
+		}
+	}
+	s.addDir(path.Dir(newDir))
+	return s.save()
+}
+
+// save writes the folders to disk
+func (s *FolderStore) save() error {
+	contents, err := json.MarshalIndent(s.folders, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(s.Path), 0o700); err != nil {
+		return err
+	}
+	return os.WriteFile(s.Path, contents, 0o600)
+}
+
+// isInDir returns whether a folder is dir or inside of it
+func isInDir(folder string, dir string) bool {
+	return folder == dir || strings.HasPrefix(folder, dir+"/")
+}
@@ -0,0 +1,34 @@
+package valfs_test
+
+import (
+	"path/filepath"
+	"testing"
+
+	vals "github.com/404wolf/valfs/valfs/vals"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFolderStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "folders.json")
+
+	folders, err := vals.NewFolderStore(path)
+	require.NoError(t, err, "Missing folders file should be empty")
+	assert.Empty(t, folders.Dirs())
+
+	require.NoError(t, folders.SetFolder("val1", "work/api"))
+	require.NoError(t, folders.AddDir("personal"))
+	assert.Equal(t, []string{"personal", "work", "work/api"}, folders.Dirs(), "Parents should be added")
+
+	require.NoError(t, folders.RenameDir("work", "projects/work"))
+	assert.Equal(t, "projects/work/api", folders.Folder("val1"), "Vals should move with their folder")
+
+	reloaded, err := vals.NewFolderStore(path)
+	require.NoError(t, err, "Failed to reload folders")
+	assert.Equal(t, folders.Dirs(), reloaded.Dirs(), "Folders should be saved")
+	assert.Equal(t, "projects/work/api", reloaded.Folder("val1"))
+
+	require.NoError(t, reloaded.RemoveDir("projects"))
+	assert.Equal(t, []string{"personal"}, reloaded.Dirs())
+	assert.Equal(t, "", reloaded.Folder("val1"), "Vals in removed folders should not be in a folder")
+}
@@ -2,6 +2,7 @@ package valfs
 
 import (
 	"context"
+	"time"
 )
 
 const ApiPageLimit = 99
@@ -30,4 +31,9 @@ type Val interface {
 
 	GetAuthorName() string
 	GetAuthorId() string
+
+	GetCreatedAt() time.Time
+	GetUrl() string
+	GetLikeCount() int32
+	GetReferenceCount() int32
 }
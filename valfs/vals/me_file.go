@@ -0,0 +1,128 @@
+package valfs
+
+import (
+	"context"
+	"encoding/json"
+	"maps"
+	"syscall"
+	"time"
+
+	common "github.com/404wolf/valfs/common"
+	memfile "github.com/404wolf/valfs/valfs/memfile"
+	"github.com/hanwen/go-fuse/v2/fs"
+)
+
+// meFile renders a read only JSON file with the profile of the authenticated
+// user and counts of their vals
+type meFile struct {
+	client  *common.Client
+	valsDir ValsContainer // Where the vals are counted from, if there is one
+}
+
+// The contents of the file
+type meInfo struct {
+	User      map[string]any `json:"user"`
+	Tier      any            `json:"tier,omitempty"`
+	Vals      ValCounts      `json:"vals"`
+	MountedAt time.Time      `json:"mountedAt"`
+}
+
+// ValCounts are the number of vals of the user, in total and by type and
+// privacy
+type ValCounts struct {
+	Total     int            `json:"total"`
+	ByType    map[string]int `json:"byType"`
+	ByPrivacy map[string]int `json:"byPrivacy"`
+}
+
+// NewMeFile creates the file with the profile of the user, which is rendered
+// every time it is opened. The vals are counted from valsDir, or listed from
+// val town if it is nil.
+func NewMeFile(
+	ctx context.Context,
+	parent *fs.Inode,
+	client *common.Client,
+	valsDir ValsContainer,
+) *memfile.RenderedFile {
+	meFile := &meFile{client: client, valsDir: valsDir}
+	renderedFile := memfile.NewRenderedFile(meFile.render)
+	parent.NewPersistentInode(ctx, renderedFile, fs.StableAttr{Mode: syscall.S_IFREG})
+	return renderedFile
+}
+
+// render renders the profile and counts of the vals
+func (f *meFile) render(ctx context.Context) ([]byte, error) {
+	counts, err := f.countVals(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// The tier is shown on its own, rather than in the profile too
+	user := maps.Clone(f.client.Profile)
+	tier := user["tier"]
+	delete(user, "tier")
+
+	data, err := json.MarshalIndent(meInfo{
+		User:      user,
+		Tier:      tier,
+		Vals:      counts,
+		MountedAt: f.client.Started,
+	}, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append(data, '\n'), nil
+}
+
+// countVals counts the vals of the user, without any requests if there is a
+// vals directory that already has them
+func (f *meFile) countVals(ctx context.Context) (ValCounts, error) {
+	if f.valsDir == nil {
+		vals, err := ListValDirVals(ctx, f.client.APIClient)
+		if err != nil {
+			return ValCounts{}, err
+		}
+		return CountVals(vals), nil
+	}
+
+	counts := newValCounts()
+	for _, valFile := range f.valsDir.ValFiles() {
+		valFile.withVal(counts.add)
+	}
+	return counts, nil
+}
+
+// CountVals counts vals by their type and privacy
+func CountVals(vals []Val) ValCounts {
+	counts := newValCounts()
+	for _, val := range vals {
+		counts.add(val)
+	}
+	return counts
+}
+
+// newValCounts makes counts where every type and privacy has no vals yet
+func newValCounts() ValCounts {
+	counts := ValCounts{
+		ByType:    make(map[string]int),
+		ByPrivacy: make(map[string]int),
+	}
+	for _, typeDir := range TypeDirs {
+		counts.ByType[typeDir] = 0
+	}
+	for _, privacy := range Privacies {
+		counts.ByPrivacy[privacy] = 0
+	}
+	return counts
+}
+
+// add counts a val
+func (counts *ValCounts) add(val Val) {
+	valType := TypeDir(val.GetValType())
+	if valType == "" {
+		valType = string(val.GetValType())
+	}
+	counts.Total++
+	counts.ByType[valType]++
+	counts.ByPrivacy[val.GetPrivacy()]++
+}
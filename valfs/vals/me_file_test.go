@@ -0,0 +1,39 @@
+package valfs_test
+
+import (
+	"testing"
+
+	vals "github.com/404wolf/valfs/valfs/vals"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCountVals(t *testing.T) {
+	cases := []struct {
+		valType string
+		privacy string
+	}{
+		{"http", vals.Public},
+		{"http", vals.Private},
+		{"script", vals.Public},
+		{"interval", vals.Unlisted},
+		{"cron", vals.Unlisted},
+	}
+
+	valsToCount := []vals.Val{}
+	for i, c := range cases {
+		val := vals.ValDirValOf(nil, string(rune('a'+i)))
+		val.SetValType(c.valType)
+		val.SetPrivacy(c.privacy)
+		valsToCount = append(valsToCount, val)
+	}
+
+	counts := vals.CountVals(valsToCount)
+	assert.Equal(t, 5, counts.Total)
+	assert.Equal(t, map[string]int{"http": 2, "script": 1, "email": 0, "cron": 2}, counts.ByType,
+		"Interval vals should be counted as cron vals, and empty types should be 0")
+	assert.Equal(t, map[string]int{vals.Public: 2, vals.Unlisted: 2, vals.Private: 1}, counts.ByPrivacy)
+
+	empty := vals.CountVals(nil)
+	assert.Equal(t, 0, empty.Total)
+	assert.Equal(t, 0, empty.ByType["http"])
+}
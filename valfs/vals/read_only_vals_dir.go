@@ -0,0 +1,229 @@
+package valfs
+
+import (
+	"context"
+	"sync"
+	"syscall"
+	"time"
+
+	common "github.com/404wolf/valfs/common"
+	"github.com/hanwen/go-fuse/v2/fs"
+	"github.com/hanwen/go-fuse/v2/fuse"
+)
+
+// ReadOnlyValsDir is a directory of read only val files, like the vals of
+// another user. Its vals are listed lazily, when the directory is first used,
+// and listed again once they are older than its time to live.
+type ReadOnlyValsDir struct {
+	fs.Inode
+
+	client *common.Client
+	list   func(ctx context.Context) ([]Val, error) // Lists the vals in the directory
+	ttl    time.Duration                            // How long listed vals are fresh for
+	onUse  func()                                   // Called whenever the directory is used, if set
+
+	stopChan    chan struct{}
+	mutex       sync.Mutex
+	refreshedAt time.Time           // When the vals were last listed
+	valFiles    map[string]*ValFile // The val files in the directory, by val id
+	filenames   map[string]string   // The names of the val files, by val id
+}
+
+var _ = (fs.NodeLookuper)((*ReadOnlyValsDir)(nil))
+var _ = (fs.NodeOpendirer)((*ReadOnlyValsDir)(nil))
+var _ = (common.Refresher)((*ReadOnlyValsDir)(nil))
+
+// NewReadOnlyValsDir creates a directory of the vals that list returns
+func NewReadOnlyValsDir(
+	ctx context.Context,
+	parent *fs.Inode,
+	client *common.Client,
+	list func(ctx context.Context) ([]Val, error),
+	ttl time.Duration,
+) *ReadOnlyValsDir {
+	dir := &ReadOnlyValsDir{
+		client:    client,
+		list:      list,
+		ttl:       ttl,
+		valFiles:  make(map[string]*ValFile),
+		filenames: make(map[string]string),
+	}
+	parent.NewPersistentInode(ctx, dir, fs.StableAttr{Mode: syscall.S_IFDIR | 0555})
+	return dir
+}
+
+// Opendir lists the vals again before the directory is read, if they are stale
+func (c *ReadOnlyValsDir) Opendir(ctx context.Context) syscall.Errno {
+	if c.onUse != nil {
+		c.onUse()
+	}
+	return c.refreshIfStale(ctx)
+}
+
+// Lookup finds a val file in the directory, listing the vals again first if
+// they are stale
+func (c *ReadOnlyValsDir) Lookup(
+	ctx context.Context,
+	name string,
+	out *fuse.EntryOut,
+) (*fs.Inode, syscall.Errno) {
+	if c.onUse != nil {
+		c.onUse()
+	}
+	if errno := c.refreshIfStale(ctx); errno != syscall.F_OK {
+		return nil, errno
+	}
+
+	child := c.GetChild(name)
+	if child == nil {
+		return nil, syscall.ENOENT
+	}
+
+	if getattrer, ok := child.Operations().(fs.NodeGetattrer); ok {
+		attrOut := fuse.AttrOut{}
+		if errno := getattrer.Getattr(ctx, nil, &attrOut); errno == syscall.F_OK {
+			out.Attr = attrOut.Attr
+		}
+	}
+
+	return child, syscall.F_OK
+}
+
+// refreshIfStale lists the vals again if they were listed longer than the
+// time to live ago
+func (c *ReadOnlyValsDir) refreshIfStale(ctx context.Context) syscall.Errno {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if time.Since(c.refreshedAt) < c.ttl {
+		return syscall.F_OK
+	}
+
+	if err := c.refresh(ctx); err != nil {
+		common.Logger.Errorf("Error listing read only vals: %v", err)
+		return common.ToErrno(err)
+	}
+
+	return syscall.F_OK
+}
+
+// Refresh lists the vals again, even if they aren't stale yet
+func (c *ReadOnlyValsDir) Refresh(ctx context.Context) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	return c.refresh(ctx)
+}
+
+// StartAutoRefresh begins listing the vals again every interval
+func (c *ReadOnlyValsDir) StartAutoRefresh(ctx context.Context, interval time.Duration) {
+	common.Logger.Infof("Starting read only vals auto-refresh with interval %v", interval)
+	if c.stopChan != nil {
+		c.StopAutoRefresh()
+	}
+
+	c.stopChan = make(chan struct{})
+	stopChan := c.stopChan
+	ticker := time.NewTicker(interval)
+
+	go func() {
+		for {
+			select {
+			case <-ticker.C:
+				if err := c.Refresh(ctx); err != nil {
+					common.Logger.Error("Error refreshing read only vals:", err)
+				}
+			case <-stopChan:
+				ticker.Stop()
+				return
+			}
+		}
+	}()
+}
+
+// StopAutoRefresh stops listing the vals again every interval
+func (c *ReadOnlyValsDir) StopAutoRefresh() {
+	if c.stopChan != nil {
+		close(c.stopChan)
+		c.stopChan = nil
+	}
+}
+
+// refresh lists the vals and brings the val files in line with them. The
+// mutex must be held.
+func (c *ReadOnlyValsDir) refresh(ctx context.Context) error {
+	newVals, err := c.list(ctx)
+	if err != nil {
+		return err
+	}
+
+	newValIds := make(map[string]bool)
+	for _, newVal := range newVals {
+		newValIds[newVal.GetId()] = true
+
+		if valFile, exists := c.valFiles[newVal.GetId()]; exists {
+			// Listed vals have no version, so they are compared by what was
+			// listed. Files can be loading the val, so it is swapped under the
+			// val's lock.
+			valFile.valMutex.Lock()
+			changed := !sameListing(valFile.Val, newVal)
+			if changed {
+				valFile.Val = newVal
+			}
+			valFile.valMutex.Unlock()
+
+			if changed {
+				valFile.ModifiedNow()
+			}
+			continue
+		}
+
+		valFile := NewReadOnlyValFile(newVal, c.client)
+		filename := c.freeFilename(newVal)
+		c.NewPersistentInode(ctx, valFile, fs.StableAttr{Mode: syscall.S_IFREG})
+		c.AddChild(filename, &valFile.Inode, true)
+		c.valFiles[newVal.GetId()] = valFile
+		c.filenames[newVal.GetId()] = filename
+	}
+
+	for valId := range c.valFiles {
+		if !newValIds[valId] {
+			c.RmChild(c.filenames[valId])
+			delete(c.valFiles, valId)
+			delete(c.filenames, valId)
+		}
+	}
+
+	c.refreshedAt = time.Now()
+
+	common.Logger.Infof("Listed %d read only vals", len(newVals))
+	return nil
+}
+
+// sameListing checks whether two vals agree on everything that listing vals
+// gives
+func sameListing(a Val, b Val) bool {
+	return a.GetName() == b.GetName() &&
+		a.GetValType() == b.GetValType() &&
+		a.GetCode() == b.GetCode() &&
+		a.GetPrivacy() == b.GetPrivacy()
+}
+
+// freeFilename returns a filename for a val that no other val in the
+// directory has. Vals of different users can have the same name, so the
+// author, and then the val id, are added to the name to tell them apart.
+func (c *ReadOnlyValsDir) freeFilename(val Val) string {
+	candidates := []string{
+		val.GetName(),
+		val.GetName() + "_" + val.GetAuthorName(),
+		val.GetName() + "_" + val.GetId(),
+	}
+
+	for _, candidate := range candidates {
+		filename := ConstructFilename(candidate, val.GetValType())
+		if c.GetChild(filename) == nil {
+			return filename
+		}
+	}
+	return ConstructFilename(candidates[len(candidates)-1], val.GetValType())
+}
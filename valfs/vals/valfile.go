@@ -2,6 +2,7 @@ package valfs
 
 import (
 	"context"
+	"sync"
 	"syscall"
 	"time"
 
@@ -10,10 +11,42 @@ import (
 	"github.com/hanwen/go-fuse/v2/fuse"
 )
 
-// ValFileFlags defines the file permissions and type for val files
-// S_IFREG indicates a regular file, 0o777 gives full rwx permissions
-const ValFileFlagsExecute = syscall.S_IFREG | 0o777
-const ValFileFlagsNoExecute = syscall.S_IFREG | 0o666
+// The permissions of val files reflect the privacy of the val. Public vals
+// can be read by others, unlisted vals only by the group, and private vals
+// only by the owner.
+var privacyPermissions = map[string]uint32{
+	Public:   0o644,
+	Unlisted: 0o640,
+	Private:  0o600,
+}
+
+// ValFileMode returns the mode of a val file for a val with the given privacy
+func ValFileMode(privacy string, executable bool) uint32 {
+	permissions, ok := privacyPermissions[privacy]
+	if !ok {
+		permissions = privacyPermissions[Public]
+	}
+
+	// Anyone who can read the val can execute it
+	if executable {
+		permissions |= (permissions & 0o444) >> 2
+	}
+
+	return syscall.S_IFREG | permissions
+}
+
+// PrivacyFromMode returns the privacy of a val that a val file with the given
+// mode should have
+func PrivacyFromMode(mode uint32) string {
+	switch {
+	case mode&0o004 != 0:
+		return Public
+	case mode&0o040 != 0:
+		return Unlisted
+	default:
+		return Private
+	}
+}
 
 // ValFile represents a file in the filesystem that corresponds to a val
 type ValFile struct {
@@ -23,6 +56,15 @@ type ValFile struct {
 	Val        Val            // Val data and operations
 	client     *common.Client // Client for API operations
 	parent     ValsContainer  // Parent directory containing this val file
+	readOnly   bool           // Whether the val can't be changed through the file
+	fresh      bool           // Whether the val was just created and not written yet
+
+	valMutex     sync.Mutex  // Guards changes to the val, fresh, and pushing them
+	pendingMutex sync.Mutex  // Guards the pending write timer
+	pendingTimer *time.Timer // Pushes debounced writes once it fires
+
+	errorMutex sync.Mutex // Guards the last error
+	lastError  string     // Last error from writing, shown in an error file
 }
 
 // Interface compliance checks
@@ -30,7 +72,8 @@ var _ = (fs.NodeSetattrer)((*ValFile)(nil))
 var _ = (fs.NodeGetattrer)((*ValFile)(nil))
 var _ = (fs.NodeWriter)((*ValFile)(nil))
 var _ = (fs.NodeOpener)((*ValFile)(nil))
-var _ = (fs.FileReader)((*ValFileHandle)(nil))
+var _ = (fs.NodeGetxattrer)((*ValFile)(nil))
+var _ = (fs.NodeListxattrer)((*ValFile)(nil))
 
 // NewValFileFromVal creates a new ValFile from complete val data
 func NewValFile(
@@ -46,10 +89,15 @@ func NewValFile(
 	}, nil
 }
 
-// ValFileHandle represents an open file handle
-type ValFileHandle struct {
-	ValFile *ValFile
-	client  *common.Client
+// NewReadOnlyValFile creates a ValFile for a val that can only be read, like
+// a val that belongs to someone else
+func NewReadOnlyValFile(val Val, client *common.Client) *ValFile {
+	return &ValFile{
+		Val:        val,
+		client:     client,
+		readOnly:   true,
+		ModifiedAt: time.Now(),
+	}
 }
 
 // ModifiedNow updates the file's modification time to current time
@@ -58,11 +106,35 @@ func (f *ValFile) ModifiedNow() {
 }
 
 func (f *ValFile) newValPackage() ValPackage {
-	return NewValPackage(
-		f.Val,
-		f.client.Config.StaticMeta,
-		f.client.Config.ExecutableVals,
-	)
+	return DraftOptionsOf(f.client.Config).ValPackage(f.Val)
+}
+
+// withVal calls read with the val while holding its lock, so that the val
+// isn't changed or replaced by a refresh while it is being read
+func (f *ValFile) withVal(read func(val Val)) {
+	f.valMutex.Lock()
+	defer f.valMutex.Unlock()
+	read(f.Val)
+}
+
+// HasPendingWrite returns whether there are debounced writes to the val that
+// have not been pushed to val town yet
+func (f *ValFile) HasPendingWrite() bool {
+	f.pendingMutex.Lock()
+	defer f.pendingMutex.Unlock()
+	return f.pendingTimer != nil
+}
+
+// load fetches the val from val town, unless there are local writes that
+// have not been pushed yet, in which case the local val is the latest
+func (f *ValFile) load(ctx context.Context) error {
+	if f.HasPendingWrite() {
+		return nil
+	}
+
+	f.valMutex.Lock()
+	defer f.valMutex.Unlock()
+	return f.Val.Load(ctx)
 }
 
 // Open handles opening the file and creates a new file handle
@@ -71,10 +143,14 @@ func (f *ValFile) Open(ctx context.Context, openFlags uint32) (
 	fuseFlags uint32,
 	errno syscall.Errno,
 ) {
-	err := f.Val.Load(ctx)
+	if f.readOnly && openFlags&(syscall.O_WRONLY|syscall.O_RDWR|syscall.O_TRUNC) != 0 {
+		return nil, 0, syscall.EROFS
+	}
+
+	err := f.load(ctx)
 	if err != nil {
 		common.Logger.Error("Error fetching val", "error", err)
-		return nil, 0, syscall.EIO
+		return nil, 0, common.ToErrno(err)
 	}
 
 	common.Logger.Info("Opening val file", "name", f.Val.GetName())
@@ -87,69 +163,201 @@ func (f *ValFile) Open(ctx context.Context, openFlags uint32) (
 	return fh, fuse.FOPEN_DIRECT_IO, syscall.F_OK
 }
 
-// Read handles reading data from the file
-func (fh *ValFileHandle) Read(
+// Write handles writing data to the file. Writes through a handle go into its
+// buffer, which is pushed when the handle is flushed.
+func (f *ValFile) Write(
 	ctx context.Context,
-	dest []byte,
+	fh fs.FileHandle,
+	data []byte,
 	off int64,
-) (fuse.ReadResult, syscall.Errno) {
-	err := fh.ValFile.Val.Load(ctx)
-	if err != nil {
-		return nil, syscall.EIO
+) (written uint32, errno syscall.Errno) {
+	if f.readOnly {
+		return 0, syscall.EROFS
 	}
 
-	valPackage := fh.ValFile.newValPackage()
-	content, err := valPackage.ToText()
-	if err != nil {
-		return nil, syscall.EIO
+	if handle, ok := fh.(*ValFileHandle); ok {
+		return handle.write(ctx, data, off)
 	}
-	bytes := []byte(*content)
 
-	end := off + int64(len(dest))
-	if end > int64(len(bytes)) {
-		end = int64(len(bytes))
+	if errno := f.commit(ctx, data); errno != syscall.F_OK {
+		return 0, errno
 	}
-	return fuse.ReadResultData(bytes[off:end]), syscall.F_OK
+	return uint32(len(data)), syscall.F_OK
 }
 
-// Write handles writing data to the file
-func (f *ValFile) Write(
-	ctx context.Context,
-	fh fs.FileHandle,
-	data []byte,
-	off int64,
-) (written uint32, errno syscall.Errno) {
-	err := f.Val.Load(ctx)
+// commit parses the full contents of the val file and pushes them to val town
+func (f *ValFile) commit(ctx context.Context, data []byte) syscall.Errno {
+	// An empty file can't hold a val, so emptying the file only clears the code
+	if len(data) == 0 {
+		text, err := f.text()
+		if err != nil {
+			return syscall.EIO
+		}
+		data = []byte(TruncateText(text, 0))
+	}
+
+	// Copying another val's file into a new val file forks that val
+	if sourceId, ok := f.forkSourceId(string(data)); ok {
+		return f.fork(ctx, sourceId, string(data))
+	}
+
+	// Writes only go to the local draft in draft mode
+	if drafts := f.drafts(); drafts != nil {
+		return f.writeDraft(drafts, data)
+	}
+
+	err := f.load(ctx)
 	if err != nil {
-		return 0, syscall.EIO
+		return common.ToErrno(err)
 	}
 
+	f.valMutex.Lock()
 	newValPackage := f.newValPackage()
 	err = newValPackage.UpdateVal(string(data))
+	if err == nil {
+		f.fresh = false
+	}
+	f.valMutex.Unlock()
 
-	if err != nil && len(data) != 0 {
+	if err != nil {
 		common.Logger.Error("Bad input ", err)
-		return 0, syscall.EINVAL
+		f.setError("Invalid val file", err)
+		return syscall.EINVAL
 	}
+	f.clearError()
+
+	return f.push(ctx)
+}
+
+// push pushes the val to val town, holding off on it if more writes may
+// follow soon
+func (f *ValFile) push(ctx context.Context) syscall.Errno {
+	if f.client.Config.WriteDebounce > 0 {
+		f.debounceUpdate()
+		f.ModifiedNow()
+		return syscall.F_OK
+	}
+
+	return f.update(ctx)
+}
 
-	err = f.Val.Update(ctx)
+// writeDraft validates data and saves it as the draft of the val
+func (f *ValFile) writeDraft(drafts *DraftStore, data []byte) syscall.Errno {
+	valPackage := f.newValPackage()
+	err := valPackage.Validate(string(data))
+	if err != nil {
+		common.Logger.Error("Bad input ", err)
+		f.setError("Invalid val file", err)
+		return syscall.EINVAL
+	}
+
+	err = drafts.Put(f.Val.GetId(), string(data), DraftOptionsOf(f.client.Config))
+	if err != nil {
+		common.Logger.Errorf("Error saving draft of val, error: %s", err)
+		f.setError("Failed to save draft", err)
+		return syscall.EIO
+	}
+	f.clearError()
+	f.ModifiedNow()
+
+	common.Logger.Infof("Saved draft of val %s", f.Val.GetId())
+	return syscall.F_OK
+}
+
+// drafts returns the store of local drafts of vals, or nil if writes go
+// straight to val town
+func (f *ValFile) drafts() *DraftStore {
+	if !f.client.Config.Drafts {
+		return nil
+	}
+	return NewDraftStore(f.client.Config.DraftsDir)
+}
+
+// text returns the contents of the val file, which is the draft of the val if
+// it has one
+func (f *ValFile) text() (string, error) {
+	if drafts := f.drafts(); drafts != nil {
+		if draft, ok := drafts.Get(f.Val.GetId()); ok {
+			return draft, nil
+		}
+	}
+
+	valPackage := f.newValPackage()
+	content, err := valPackage.ToText()
+	if err != nil {
+		return "", err
+	}
+	return *content, nil
+}
+
+// update pushes the val to val town, and then reloads it to pick up the new
+// version
+func (f *ValFile) update(ctx context.Context) syscall.Errno {
+	f.valMutex.Lock()
+	defer f.valMutex.Unlock()
+
+	err := f.Val.Update(ctx)
 	if err != nil {
 		common.Logger.Errorf("Error updating val, error: %s", err)
-		return 0, syscall.EIO
+		f.setError("Failed to update val on Val Town", err)
+		return common.ToErrno(err)
 	}
+	f.clearError()
 
 	if !f.client.Config.StaticMeta {
 		err = f.Val.Load(ctx)
 		if err != nil {
-			return 0, syscall.EIO
+			return common.ToErrno(err)
 		}
 		f.ModifiedNow()
 	}
 
-	filename := ConstructFilename(f.Val.GetName(), f.Val.GetValType())
-	waitThenMaybeDenoCache(filename, f.client)
+	waitThenMaybeDenoCache(f.path(), f.client)
 
-	return uint32(len(data)), syscall.F_OK
+	return syscall.F_OK
+}
+
+// path returns the path of the val file relative to the vals directory,
+// including the folder or type directory that it is in
+func (f *ValFile) path() string {
+	if _, dir := f.Parent(); dir != nil && f.parent != nil {
+		return f.Path(f.parent.GetInode())
+	}
+	return ConstructLayoutPath(f.client.Config.Layout, f.Val.GetName(), f.Val.GetValType())
+}
+
+// debounceUpdate schedules the val to be pushed to val town once no more
+// writes have happened for the configured debounce window, so that a burst of
+// saves only creates one new version
+func (f *ValFile) debounceUpdate() {
+	f.pendingMutex.Lock()
+	defer f.pendingMutex.Unlock()
+
+	window := time.Duration(f.client.Config.WriteDebounce) * time.Millisecond
+	if f.pendingTimer != nil {
+		f.pendingTimer.Reset(window)
+		return
+	}
+
+	common.Logger.Infof("Debouncing writes to val %s for %v", f.Val.GetId(), window)
+	f.pendingTimer = time.AfterFunc(window, func() {
+		f.FlushWrite(context.Background())
+	})
+}
+
+// FlushWrite immediately pushes any debounced writes to val town
+func (f *ValFile) FlushWrite(ctx context.Context) syscall.Errno {
+	f.pendingMutex.Lock()
+	if f.pendingTimer == nil {
+		f.pendingMutex.Unlock()
+		return syscall.F_OK
+	}
+	f.pendingTimer.Stop()
+	f.pendingTimer = nil
+	f.pendingMutex.Unlock()
+
+	common.Logger.Infof("Pushing debounced writes to val %s", f.Val.GetId())
+	return f.update(ctx)
 }
 
 // Getattr retrieves the file attributes
@@ -160,11 +368,7 @@ func (f *ValFile) Getattr(
 ) syscall.Errno {
 	common.Logger.Info("Getting attributes for val file", "name", f.Val.GetName())
 
-	valPackage := NewValPackage(
-		f.Val,
-		f.client.Config.StaticMeta,
-		f.client.Config.ExecutableVals,
-	)
+	valPackage := f.newValPackage()
 
 	// We do noy want to fetch all the contents of the val using .Load, since
 	// this method needs to be super fast (it's called a lot). By default we will
@@ -173,7 +377,12 @@ func (f *ValFile) Getattr(
 	// author id. If we haven't loaded this, then we definitely haven't loaded
 	// the other extended attributes either. In this case, don't bother, just
 	// don't specify a size.
-	if f.Val.GetAuthorId() != "" {
+	if handle, ok := fh.(*ValFileHandle); ok && handle.isDirty() {
+		out.Size = uint64(handle.size())
+	} else if drafts := f.drafts(); drafts != nil && drafts.Has(f.Val.GetId()) {
+		draft, _ := drafts.Get(f.Val.GetId())
+		out.Size = uint64(len(draft))
+	} else if f.Val.GetAuthorId() != "" {
 		contentLen, err := valPackage.Len()
 		if err != nil {
 			common.Logger.Error("Error getting content length", "error", err)
@@ -199,8 +408,25 @@ func (f *ValFile) Setattr(
 ) syscall.Errno {
 	common.Logger.Info("Setting attributes for val file", "name", f.Val.GetName())
 
-	out.Size = in.Size
-	f.assignValMode(out)
+	if f.readOnly {
+		return syscall.EROFS
+	}
+
+	if mode, ok := in.GetMode(); ok {
+		if errno := f.chmod(ctx, mode); errno != syscall.F_OK {
+			return errno
+		}
+	}
+
+	if size, ok := in.GetSize(); ok {
+		if errno := f.truncate(ctx, fh, int64(size)); errno != syscall.F_OK {
+			return errno
+		}
+	}
+
+	if errno := f.Getattr(ctx, fh, out); errno != syscall.F_OK {
+		return errno
+	}
 	out.Atime = in.Atime
 	out.Mtime = in.Mtime
 	out.Ctime = in.Ctime
@@ -208,10 +434,93 @@ func (f *ValFile) Setattr(
 	return syscall.F_OK
 }
 
+// truncate changes the size of the val file. Through a handle this resizes the
+// handle's buffer like a regular file. Otherwise it applies to the val right
+// away, and keeps the frontmatter whole, so truncating into the frontmatter
+// only clears the code.
+func (f *ValFile) truncate(ctx context.Context, fh fs.FileHandle, size int64) syscall.Errno {
+	if handle, ok := fh.(*ValFileHandle); ok {
+		return handle.truncate(ctx, size)
+	}
+
+	common.Logger.Infof("Truncating val %s to %d bytes", f.Val.GetId(), size)
+
+	err := f.load(ctx)
+	if err != nil {
+		return common.ToErrno(err)
+	}
+
+	text, err := f.text()
+	if err != nil {
+		return syscall.EIO
+	}
+
+	return f.commit(ctx, []byte(TruncateText(text, size)))
+}
+
+// chmod changes the privacy of the val to the one that the mode stands for
+func (f *ValFile) chmod(ctx context.Context, mode uint32) syscall.Errno {
+	privacy := PrivacyFromMode(mode)
+	if privacy == f.Val.GetPrivacy() {
+		return syscall.F_OK
+	}
+
+	// Privacy changes would go live right away, which drafts are meant to avoid
+	if f.drafts() != nil {
+		common.Logger.Warnf("Cannot change privacy of val %s in draft mode", f.Val.GetId())
+		return syscall.EPERM
+	}
+
+	common.Logger.Infof("Changing privacy of val %s to %s", f.Val.GetId(), privacy)
+	f.valMutex.Lock()
+	previousPrivacy := f.Val.GetPrivacy()
+	f.Val.SetPrivacy(privacy)
+	f.valMutex.Unlock()
+
+	errno := f.update(ctx)
+	if errno != syscall.F_OK {
+		f.valMutex.Lock()
+		f.Val.SetPrivacy(previousPrivacy)
+		f.valMutex.Unlock()
+	}
+	return errno
+}
+
 func (f *ValFile) assignValMode(out *fuse.AttrOut) {
-	if f.client.Config.ExecutableVals {
-		out.Mode = ValFileFlagsExecute
-	} else {
-		out.Mode = ValFileFlagsNoExecute
+	out.Mode = ValFileMode(f.Val.GetPrivacy(), f.client.Config.ExecutableVals)
+	if f.readOnly {
+		out.Mode &^= 0o222
+	}
+}
+
+// Getxattr reports whether the val has an unpublished draft
+func (f *ValFile) Getxattr(
+	ctx context.Context,
+	attr string,
+	dest []byte,
+) (uint32, syscall.Errno) {
+	drafts := f.drafts()
+	if attr != DraftXattr || drafts == nil || !drafts.Has(f.Val.GetId()) {
+		return 0, fs.ENOATTR
+	}
+
+	value := []byte("true")
+	if len(dest) < len(value) {
+		return uint32(len(value)), syscall.ERANGE
+	}
+	return uint32(copy(dest, value)), syscall.F_OK
+}
+
+// Listxattr lists the draft attribute if the val has an unpublished draft
+func (f *ValFile) Listxattr(ctx context.Context, dest []byte) (uint32, syscall.Errno) {
+	drafts := f.drafts()
+	if drafts == nil || !drafts.Has(f.Val.GetId()) {
+		return 0, syscall.F_OK
+	}
+
+	names := []byte(DraftXattr + "\x00")
+	if len(dest) < len(names) {
+		return uint32(len(names)), syscall.ERANGE
 	}
+	return uint32(copy(dest, names)), syscall.F_OK
 }
@@ -0,0 +1,121 @@
+package valfs
+
+import (
+	"context"
+	"fmt"
+	"syscall"
+	"time"
+
+	common "github.com/404wolf/valfs/common"
+	memfile "github.com/404wolf/valfs/valfs/memfile"
+	"github.com/hanwen/go-fuse/v2/fs"
+	"github.com/hanwen/go-fuse/v2/fuse"
+)
+
+// ValErrorFileFlags defines the file permissions and type for error files
+const ValErrorFileFlags = syscall.S_IFREG | 0o444
+
+// ValErrorFile is a read only file next to a val file that holds the last
+// error from writing to it. It only exists while there is an error.
+type ValErrorFile struct {
+	fs.Inode
+
+	ValFile *ValFile // The val file whose error this is
+}
+
+// Interface compliance checks
+var _ = (fs.NodeGetattrer)((*ValErrorFile)(nil))
+var _ = (fs.NodeOpener)((*ValErrorFile)(nil))
+var _ = (fs.NodeReader)((*ValErrorFile)(nil))
+
+// Open handles opening the error file
+func (f *ValErrorFile) Open(ctx context.Context, openFlags uint32) (
+	fh fs.FileHandle,
+	fuseFlags uint32,
+	errno syscall.Errno,
+) {
+	if openFlags&(syscall.O_WRONLY|syscall.O_RDWR) != 0 {
+		return nil, 0, syscall.EACCES
+	}
+	return nil, fuse.FOPEN_DIRECT_IO, syscall.F_OK
+}
+
+// Read handles reading the last error of the val file
+func (f *ValErrorFile) Read(
+	ctx context.Context,
+	fh fs.FileHandle,
+	dest []byte,
+	off int64,
+) (fuse.ReadResult, syscall.Errno) {
+	return memfile.ReadAt([]byte(f.ValFile.LastError()), dest, off), syscall.F_OK
+}
+
+// Getattr retrieves the error file attributes
+func (f *ValErrorFile) Getattr(
+	ctx context.Context,
+	fh fs.FileHandle,
+	out *fuse.AttrOut,
+) syscall.Errno {
+	out.Size = uint64(len(f.ValFile.LastError()))
+	out.Mode = ValErrorFileFlags
+	return syscall.F_OK
+}
+
+// LastError returns the last error from writing to the val file, or an empty
+// string if the last write succeeded
+func (f *ValFile) LastError() string {
+	f.errorMutex.Lock()
+	defer f.errorMutex.Unlock()
+	return f.lastError
+}
+
+// setError records an error from writing to the val file, and shows it in an
+// error file next to the val file
+func (f *ValFile) setError(description string, err error) {
+	f.errorMutex.Lock()
+	f.lastError = fmt.Sprintf(
+		"%s: %s\n%s\n",
+		time.Now().Format(time.RFC3339),
+		description,
+		err,
+	)
+	f.errorMutex.Unlock()
+
+	// The error file goes in whichever folder the val file is in
+	_, dir := f.Parent()
+	if dir == nil {
+		return
+	}
+
+	filename := f.errorFilename()
+	if dir.GetChild(filename) == nil {
+		errorFile := &ValErrorFile{ValFile: f}
+		inode := dir.NewPersistentInode(
+			context.Background(),
+			errorFile,
+			fs.StableAttr{Mode: syscall.S_IFREG, Ino: 0},
+		)
+		dir.AddChild(filename, inode, true)
+		common.Logger.Infof("Added error file %s", filename)
+	}
+}
+
+// clearError forgets the last error from writing to the val file, and removes
+// its error file
+func (f *ValFile) clearError() {
+	f.errorMutex.Lock()
+	hadError := f.lastError != ""
+	f.lastError = ""
+	f.errorMutex.Unlock()
+
+	if _, dir := f.Parent(); hadError && dir != nil {
+		dir.RmChild(f.errorFilename())
+	}
+}
+
+// errorFilename returns the name of the error file of the val file
+func (f *ValFile) errorFilename() string {
+	return ConstructErrorFilename(
+		ConstructLayoutFilename(f.client.Config.Layout, f.Val.GetName(), f.Val.GetValType()),
+	)
+}
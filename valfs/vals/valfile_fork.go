@@ -0,0 +1,130 @@
+package valfs
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"syscall"
+
+	common "github.com/404wolf/valfs/common"
+)
+
+// forkSourceId returns the id of the val that contents are the val file of,
+// if the val file was just created and contents are from a different val's
+// file, like when a val file is copied into the vals directory
+func (f *ValFile) forkSourceId(contents string) (string, bool) {
+	f.valMutex.Lock()
+	fresh := f.fresh
+	valId := f.Val.GetId()
+	f.valMutex.Unlock()
+
+	if !fresh {
+		return "", false
+	}
+
+	_, meta, err := deconstructVal(contents)
+	if err != nil || meta.Id == nil || *meta.Id == valId {
+		return "", false
+	}
+	return *meta.Id, true
+}
+
+// fork turns the val into a fork of the val with sourceId, using the code in
+// contents and the type and readme of the source val. Where the val was forked
+// from is added to the end of its readme. Forking isn't allowed in draft mode,
+// since the forked readme and type would go live right away.
+func (f *ValFile) fork(ctx context.Context, sourceId string, contents string) syscall.Errno {
+	if f.drafts() != nil {
+		common.Logger.Warnf("Cannot fork val %s into %s in draft mode", sourceId, f.Val.GetId())
+		return syscall.EPERM
+	}
+
+	code, meta, err := deconstructVal(contents)
+	if err != nil {
+		return syscall.EINVAL
+	}
+
+	source := ValDirValOf(f.client.APIClient, sourceId)
+	err = source.Load(ctx)
+	if err != nil {
+		common.Logger.Errorf("Error fetching val %s to fork: %v", sourceId, err)
+		f.setError("Failed to fetch the val to fork", err)
+		return common.ToErrno(err)
+	}
+
+	readme := source.GetReadme()
+	if meta.ReadMe != nil {
+		readme = *meta.ReadMe
+	}
+
+	version := source.GetVersion()
+	if meta.Version != nil {
+		version = *meta.Version
+	}
+
+	provenance := fmt.Sprintf(
+		"Forked from [%s/%s](%s) (id %s, version %d)",
+		source.GetAuthorName(),
+		source.GetName(),
+		getWebsiteLink(source.GetAuthorName(), source.GetName()),
+		sourceId,
+		version,
+	)
+	if readme = strings.TrimRight(readme, "\n"); readme != "" {
+		readme += "\n\n"
+	}
+	readme += provenance
+
+	common.Logger.Infof("Forking val %s into %s", sourceId, f.Val.GetId())
+	f.valMutex.Lock()
+	oldType := f.Val.GetValType()
+	f.Val.SetCode(*code)
+	f.Val.SetValType(string(source.GetValType()))
+	f.Val.SetReadme(readme)
+	f.valMutex.Unlock()
+
+	if errno := f.update(ctx); errno != syscall.F_OK {
+		return errno
+	}
+
+	f.valMutex.Lock()
+	f.fresh = false
+	f.valMutex.Unlock()
+
+	if f.Val.GetValType() != oldType {
+		f.moveToType(oldType)
+	}
+
+	return syscall.F_OK
+}
+
+// moveToType renames the val file, and the files next to it, to match the
+// val's type after it changed from oldType
+func (f *ValFile) moveToType(oldType ValType) {
+	name, dir := f.Parent()
+	if dir == nil {
+		return
+	}
+
+	layout := f.client.Config.Layout
+	valName := f.Val.GetName()
+	newName := ConstructLayoutFilename(layout, valName, f.Val.GetValType())
+
+	newDir := dir
+	if layout == LayoutByType {
+		_, valsInode := dir.Parent()
+		if valsInode == nil || valsInode.GetChild(TypeDir(f.Val.GetValType())) == nil {
+			return
+		}
+		newDir = valsInode.GetChild(TypeDir(f.Val.GetValType()))
+	}
+
+	common.Logger.Infof("Moving val file %s to %s for its new type", name, newName)
+	dir.MvChild(name, newDir, newName, true)
+	dir.MvChild(ConstructReadmeFilename(valName), newDir, ConstructReadmeFilename(valName), true)
+	dir.MvChild(ConstructErrorFilename(name), newDir, ConstructErrorFilename(newName), true)
+
+	// Let the kernel know the old name is gone, outside of the request that
+	// moved it
+	go dir.NotifyEntry(name)
+}
@@ -0,0 +1,161 @@
+package valfs
+
+import (
+	"context"
+	"sync"
+	"syscall"
+
+	common "github.com/404wolf/valfs/common"
+	memfile "github.com/404wolf/valfs/valfs/memfile"
+	"github.com/hanwen/go-fuse/v2/fs"
+	"github.com/hanwen/go-fuse/v2/fuse"
+)
+
+// ValFileHandle represents an open file handle. Writes and size changes made
+// through the handle are collected in its buffer, and the buffer is pushed as
+// a whole once the handle is flushed, so that the val file behaves like a
+// regular file while it is being written.
+type ValFileHandle struct {
+	ValFile *ValFile
+	client  *common.Client
+
+	mutex  sync.Mutex // Guards the buffer
+	buffer []byte     // Contents of the file as written through this handle
+	loaded bool       // Whether the buffer holds the contents of the file
+	dirty  bool       // Whether the buffer has changes that were not pushed
+}
+
+// Interface compliance checks
+var _ = (fs.FileReader)((*ValFileHandle)(nil))
+var _ = (fs.FileFlusher)((*ValFileHandle)(nil))
+var _ = (fs.FileFsyncer)((*ValFileHandle)(nil))
+
+// Read handles reading data from the file
+func (fh *ValFileHandle) Read(
+	ctx context.Context,
+	dest []byte,
+	off int64,
+) (fuse.ReadResult, syscall.Errno) {
+	fh.mutex.Lock()
+	defer fh.mutex.Unlock()
+
+	// Changes that weren't pushed yet are read back from the buffer
+	if fh.dirty {
+		return memfile.ReadAt(fh.buffer, dest, off), syscall.F_OK
+	}
+
+	err := fh.ValFile.load(ctx)
+	if err != nil {
+		return nil, common.ToErrno(err)
+	}
+
+	content, err := fh.ValFile.text()
+	if err != nil {
+		return nil, syscall.EIO
+	}
+
+	return memfile.ReadAt([]byte(content), dest, off), syscall.F_OK
+}
+
+// write writes data into the buffer at the given offset
+func (fh *ValFileHandle) write(
+	ctx context.Context,
+	data []byte,
+	off int64,
+) (uint32, syscall.Errno) {
+	fh.mutex.Lock()
+	defer fh.mutex.Unlock()
+
+	if errno := fh.load(ctx); errno != syscall.F_OK {
+		return 0, errno
+	}
+
+	if end := off + int64(len(data)); end > int64(len(fh.buffer)) {
+		fh.buffer = append(fh.buffer, make([]byte, end-int64(len(fh.buffer)))...)
+	}
+	copy(fh.buffer[off:], data)
+	fh.dirty = true
+
+	return uint32(len(data)), syscall.F_OK
+}
+
+// truncate resizes the buffer, padding it with zero bytes if it grows
+func (fh *ValFileHandle) truncate(ctx context.Context, size int64) syscall.Errno {
+	fh.mutex.Lock()
+	defer fh.mutex.Unlock()
+
+	if !fh.loaded && size != 0 {
+		if errno := fh.load(ctx); errno != syscall.F_OK {
+			return errno
+		}
+	}
+
+	if size <= int64(len(fh.buffer)) {
+		fh.buffer = fh.buffer[:size]
+	} else {
+		fh.buffer = append(fh.buffer, make([]byte, size-int64(len(fh.buffer)))...)
+	}
+	fh.loaded = true
+	fh.dirty = true
+
+	return syscall.F_OK
+}
+
+// load fills the buffer with the current contents of the file, if it hasn't
+// been filled yet
+func (fh *ValFileHandle) load(ctx context.Context) syscall.Errno {
+	if fh.loaded {
+		return syscall.F_OK
+	}
+
+	err := fh.ValFile.load(ctx)
+	if err != nil {
+		return common.ToErrno(err)
+	}
+
+	content, err := fh.ValFile.text()
+	if err != nil {
+		return syscall.EIO
+	}
+
+	fh.buffer = []byte(content)
+	fh.loaded = true
+	return syscall.F_OK
+}
+
+// isDirty returns whether the buffer has changes that were not pushed
+func (fh *ValFileHandle) isDirty() bool {
+	fh.mutex.Lock()
+	defer fh.mutex.Unlock()
+	return fh.dirty
+}
+
+// size returns the size of the buffer
+func (fh *ValFileHandle) size() int {
+	fh.mutex.Lock()
+	defer fh.mutex.Unlock()
+	return len(fh.buffer)
+}
+
+// Flush pushes the changes in the buffer when the file is closed
+func (fh *ValFileHandle) Flush(ctx context.Context) syscall.Errno {
+	fh.mutex.Lock()
+	defer fh.mutex.Unlock()
+
+	if !fh.dirty {
+		return syscall.F_OK
+	}
+
+	errno := fh.ValFile.commit(ctx, fh.buffer)
+	if errno != syscall.F_OK {
+		return errno
+	}
+	fh.dirty = false
+
+	return syscall.F_OK
+}
+
+// Fsync pushes the changes in the buffer
+func (fh *ValFileHandle) Fsync(ctx context.Context, flags uint32) syscall.Errno {
+	return fh.Flush(ctx)
+}
@@ -4,7 +4,10 @@ import (
 	"errors"
 	"fmt"
 	"regexp"
+	"slices"
+	"strconv"
 	"strings"
+	"time"
 
 	common "github.com/404wolf/valfs/common"
 	"github.com/goccy/go-yaml"
@@ -17,6 +20,37 @@ type ValPackage struct {
 
 	StaticMeta     bool
 	ExecutableVals bool
+	ReadmeFiles    bool     // Readme lives in its own file, not the frontmatter
+	Fields         []string // Frontmatter fields to show, or nil to show all
+}
+
+// The names of the fields that can be shown in the frontmatter
+const (
+	FieldId         = "id"
+	FieldVersion    = "version"
+	FieldType       = "type"
+	FieldAuthor     = "author"
+	FieldPrivacy    = "privacy"
+	FieldCreatedAt  = "createdAt"
+	FieldLikes      = "likes"
+	FieldReferences = "references"
+	FieldLinks      = "links"
+	FieldReadme     = "readme"
+)
+
+// FrontmatterFields lists all the fields that can be shown in the frontmatter,
+// in the order they appear in
+var FrontmatterFields = []string{
+	FieldId,
+	FieldVersion,
+	FieldType,
+	FieldAuthor,
+	FieldPrivacy,
+	FieldCreatedAt,
+	FieldLikes,
+	FieldReferences,
+	FieldLinks,
+	FieldReadme,
 }
 
 // valPackageFrontmatterLinks contains all the external links and references
@@ -29,11 +63,16 @@ type valPackageFrontmatterLinks struct {
 
 // valPackageFrontmatter represents the metadata section of a val package
 type valPackageFrontmatter struct {
-	Id      string                     `yaml:"id" lc:"🔒"`
-	Version int32                      `yaml:"version" lc:"🔒 (reopen file to see change)"`
-	Privacy string                     `yaml:"privacy" lc:"(public|private|unlisted)"`
-	Links   valPackageFrontmatterLinks `yaml:"links"`
-	ReadMe  string                     `yaml:"readme"`
+	Id         *string                     `yaml:"id,omitempty" lc:"🔒"`
+	Version    *int32                      `yaml:"version,omitempty" lc:"🔒 (reopen file to see change)"`
+	Type       *string                     `yaml:"type,omitempty" lc:"🔒 (rename file to change)"`
+	Author     *string                     `yaml:"author,omitempty" lc:"🔒"`
+	Privacy    *string                     `yaml:"privacy,omitempty" lc:"(public|private|unlisted)"`
+	CreatedAt  *string                     `yaml:"createdAt,omitempty" lc:"🔒"`
+	Likes      *int32                      `yaml:"likes,omitempty" lc:"🔒"`
+	References *int32                      `yaml:"references,omitempty" lc:"🔒"`
+	Links      *valPackageFrontmatterLinks `yaml:"links,omitempty"`
+	ReadMe     *string                     `yaml:"readme,omitempty"`
 }
 
 // NewValPackage creates a new val package from a val
@@ -45,19 +84,47 @@ func NewValPackage(val Val, staticMeta bool, executableVals bool) ValPackage {
 	}
 }
 
-// ToText converts the val to a package with metadata and code
+// The byte order mark that may be at the very start of a val's code
+const byteOrderMark = "\uFEFF"
+
+// The separator between the frontmatter (or shebang) and what follows it
+const frontmatterSeparator = "\n\n"
+
+// Matches the first frontmatter block between /*--- and ---*/
+var frontmatterRe = regexp.MustCompile(`(?s)/\*---\r?\n(.*?)\r?\n---\*/`)
+
+// ToText converts the val to a package with metadata and code. The code is
+// kept byte for byte, and the lines we add around it use the same line endings
+// as the code, so that the text parses back to exactly the same code.
 func (v *ValPackage) ToText() (*string, error) {
 	frontmatter, err := v.getFrontmatterText()
 	if err != nil {
 		return nil, err
 	}
 
-	combined := frontmatter + v.Val.GetCode()
+	code := v.Val.GetCode()
+	if code == EmptyValCode {
+		code = ""
+	}
+
+	// A byte order mark must stay at the start of the file, after the shebang
+	code, hasBOM := strings.CutPrefix(code, byteOrderMark)
+
+	header := frontmatter + frontmatterSeparator
+	if strings.Contains(code, "\r\n") {
+		header = strings.ReplaceAll(header, "\n", "\r\n")
+	}
+	if hasBOM {
+		header = byteOrderMark + header
+	}
 
+	// The shebang always ends in a plain newline, or the kernel would look for
+	// an interpreter whose name ends in a carriage return
 	if v.ExecutableVals {
-		combined = AffixShebang(combined)
+		header = AffixShebang(header)
 	}
 
+	combined := header + code
 	return &combined, nil
 }
 
@@ -73,59 +140,201 @@ func (v *ValPackage) Len() (int, error) {
 
 // UpdateVal sets the contents of a val package and updates the underlying val
 func (v *ValPackage) UpdateVal(contents string) error {
-	code, frontmatter, err := deconstructVal(contents)
+	code, frontmatter, err := v.parse(contents)
 	if err != nil {
 		common.Logger.Error("Error deconstructing val", err)
 		return err
 	}
 
 	// Update the underlying val
-	v.Val.SetPrivacy(frontmatter.Privacy)
-	v.Val.SetReadme(frontmatter.ReadMe)
+	if frontmatter.Privacy != nil {
+		v.Val.SetPrivacy(*frontmatter.Privacy)
+	}
+	if frontmatter.ReadMe != nil {
+		v.Val.SetReadme(*frontmatter.ReadMe)
+	}
 	v.Val.SetCode(*code)
 
 	return nil
 }
 
-// deconstructVal breaks apart a val into its metadata and code contents
+// Validate checks that contents could be used to update the val, without
+// updating it
+func (v *ValPackage) Validate(contents string) error {
+	_, _, err := v.parse(contents)
+	return err
+}
+
+// parse breaks apart the contents of a val file and checks that its
+// frontmatter is valid for the val
+func (v *ValPackage) parse(contents string) (
+	code *string,
+	meta *valPackageFrontmatter,
+	err error,
+) {
+	code, meta, err = deconstructVal(contents)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	err = v.validateFrontmatter(contents, meta)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return code, meta, nil
+}
+
+// deconstructVal breaks apart a val into its metadata and code contents. The
+// code is everything after the separator following the frontmatter, exactly
+// as it was written.
 func deconstructVal(contents string) (
 	code *string,
 	meta *valPackageFrontmatter,
 	err error,
 ) {
-	// Match the first frontmatter block between /*--- and ---*/
-	frontmatterRe := regexp.MustCompile(`(?s)/\*---\n(.*?)\n---\*/`)
-	matches := frontmatterRe.FindStringSubmatch(contents)
-	if len(matches) < 2 {
+	shebang, contents := cutShebang(contents)
+	contents, hasBOM := strings.CutPrefix(contents, byteOrderMark)
+
+	matches := frontmatterRe.FindStringSubmatchIndex(contents)
+	if matches == nil {
 		return nil, nil, errors.New("No frontmatter found")
 	}
 
-	// Extract just the YAML content
-	frontmatterContent := matches[1]
+	// Extract just the YAML content. The line endings of the frontmatter only
+	// follow those of the code, so they are normalized before parsing.
+	frontmatterContent := contents[matches[2]:matches[3]]
+	frontmatterContent = strings.ReplaceAll(frontmatterContent, "\r\n", "\n")
 
 	// Parse the frontmatter YAML
 	meta = &valPackageFrontmatter{}
-	err = yaml.Unmarshal([]byte(frontmatterContent), meta)
+	err = yaml.UnmarshalWithOptions(
+		[]byte(frontmatterContent),
+		meta,
+		yaml.DisallowUnknownField(),
+	)
 	if err != nil {
-		return nil, nil, fmt.Errorf("failed to parse frontmatter: %w", err)
+		lineOffset := strings.Count(shebang+contents[:matches[2]], "\n")
+		return nil, nil, newFrontmatterError(err, lineOffset)
 	}
 
-	// Find the start and end positions of the frontmatter block
-	frontmatterEnd := strings.Index(contents, "---*/") + 5
+	// Extract code section after the first frontmatter block, dropping only the
+	// separator that ToText puts there
+	codeSection := trimFrontmatterSeparator(contents[matches[1]:])
+	if hasBOM {
+		codeSection = byteOrderMark + codeSection
+	}
 
-	// Extract code section after the first frontmatter block
-	codeSection := strings.TrimSpace(contents[frontmatterEnd:])
+	return &codeSection, meta, nil
+}
 
-	// Handle empty code case
-	if codeSection == "" {
-		codeSection = " "
+// cutShebang splits the shebang line, and the separator after it, off the
+// start of the text of a val file
+func cutShebang(contents string) (shebang string, rest string) {
+	if !strings.HasPrefix(contents, "#!") {
+		return "", contents
 	}
 
-	return &codeSection, meta, nil
+	end := strings.Index(contents, "\n")
+	if end == -1 {
+		return contents, ""
+	}
+	end++
+	if strings.HasPrefix(contents[end:], "\n") {
+		end++
+	}
+	return contents[:end], contents[end:]
+}
+
+// FrontmatterError is an error in the frontmatter of a val file, located by
+// its line and column in the whole file
+type FrontmatterError struct {
+	Line    int
+	Column  int
+	Message string
+}
+
+// Matches the position that YAML errors are prefixed with
+var yamlErrorPositionRe = regexp.MustCompile(`(?s)^\[(\d+):(\d+)\]\s*(.*)$`)
+
+// newFrontmatterError converts an error from parsing the frontmatter YAML into
+// an error located in the val file. lineOffset is the number of lines in the
+// file before the YAML starts.
+func newFrontmatterError(err error, lineOffset int) error {
+	message := err.Error()
+	var formatter interface{ FormatError(bool, bool) string }
+	if errors.As(err, &formatter) {
+		message = formatter.FormatError(false, false)
+	}
+
+	matches := yamlErrorPositionRe.FindStringSubmatch(message)
+	if matches == nil {
+		return fmt.Errorf("failed to parse frontmatter: %w", err)
+	}
+
+	line, _ := strconv.Atoi(matches[1])
+	column, _ := strconv.Atoi(matches[2])
+	return &FrontmatterError{
+		Line:    line + lineOffset,
+		Column:  column,
+		Message: strings.TrimSpace(matches[3]),
+	}
+}
+
+func (e *FrontmatterError) Error() string {
+	return fmt.Sprintf("invalid frontmatter at line %d, column %d: %s", e.Line, e.Column, e.Message)
+}
+
+// trimFrontmatterSeparator removes the separator between the frontmatter and
+// the code, or whatever is left of it if it was partially deleted
+func trimFrontmatterSeparator(code string) string {
+	for _, separator := range []string{"\r\n\r\n", "\n\n", "\r\n", "\n"} {
+		if trimmed, ok := strings.CutPrefix(code, separator); ok {
+			return trimmed
+		}
+	}
+	return code
+}
+
+// TruncateText cuts the text of a val file down to size bytes, or pads it
+// with zero bytes up to size. The frontmatter is always kept whole, so
+// truncating into it only clears the code.
+func TruncateText(text string, size int64) string {
+	if size >= int64(len(text)) {
+		return text + strings.Repeat("\x00", int(size-int64(len(text))))
+	}
+
+	if headerEnd := frontmatterEnd(text); size < headerEnd {
+		size = headerEnd
+	}
+	return text[:size]
+}
+
+// frontmatterEnd returns the offset in the text of a val file at which the
+// code starts, or 0 if there is no frontmatter
+func frontmatterEnd(text string) int64 {
+	matches := frontmatterRe.FindStringIndex(text)
+	if matches == nil {
+		return 0
+	}
+
+	rest := text[matches[1]:]
+	return int64(len(text) - len(trimFrontmatterSeparator(rest)))
 }
 
 // getFrontmatterText returns the metadata formatted as YAML with comment markers
 func (v *ValPackage) getFrontmatterText() (string, error) {
+	frontmatterYAML, err := yamlcomment.Marshal(v.getFrontmatter())
+	if err != nil {
+		return "", err
+	}
+
+	return "/*---\n" + string(frontmatterYAML) + "---*/", nil
+}
+
+// getFrontmatter returns the metadata of the val that is shown in the
+// frontmatter
+func (v *ValPackage) getFrontmatter() valPackageFrontmatter {
 	moduleLink := v.Val.GetModuleLink()
 
 	if v.StaticMeta {
@@ -146,20 +355,63 @@ func (v *ValPackage) getFrontmatterText() (string, error) {
 		frontmatterValLinks.Email = &emailAddress
 	}
 
-	frontmatterVal := valPackageFrontmatter{
-		Id:      v.Val.GetId(),
-		Version: v.Val.GetVersion(),
-		Privacy: v.Val.GetPrivacy(),
-		Links:   frontmatterValLinks,
-		ReadMe:  v.Val.GetReadme(),
+	frontmatterVal := valPackageFrontmatter{}
+
+	if v.showField(FieldId) {
+		id := v.Val.GetId()
+		frontmatterVal.Id = &id
 	}
 
-	frontmatterYAML, err := yamlcomment.Marshal(frontmatterVal)
-	if err != nil {
-		return "", err
+	if v.showField(FieldVersion) {
+		version := v.Val.GetVersion()
+		frontmatterVal.Version = &version
+	}
+
+	if v.showField(FieldType) {
+		valType := string(v.Val.GetValType())
+		frontmatterVal.Type = &valType
+	}
+
+	if v.showField(FieldAuthor) {
+		author := v.Val.GetAuthorName()
+		frontmatterVal.Author = &author
+	}
+
+	if v.showField(FieldPrivacy) {
+		privacy := v.Val.GetPrivacy()
+		frontmatterVal.Privacy = &privacy
+	}
+
+	if v.showField(FieldCreatedAt) && !v.Val.GetCreatedAt().IsZero() {
+		createdAt := v.Val.GetCreatedAt().UTC().Format(time.RFC3339)
+		frontmatterVal.CreatedAt = &createdAt
 	}
 
-	return "/*---\n" + string(frontmatterYAML) + "---*/\n\n", nil
+	if v.showField(FieldLikes) {
+		likes := v.Val.GetLikeCount()
+		frontmatterVal.Likes = &likes
+	}
+
+	if v.showField(FieldReferences) {
+		references := v.Val.GetReferenceCount()
+		frontmatterVal.References = &references
+	}
+
+	if v.showField(FieldLinks) {
+		frontmatterVal.Links = &frontmatterValLinks
+	}
+
+	if v.showField(FieldReadme) && !v.ReadmeFiles {
+		readme := v.Val.GetReadme()
+		frontmatterVal.ReadMe = &readme
+	}
+
+	return frontmatterVal
+}
+
+// showField returns whether a field should be shown in the frontmatter
+func (v *ValPackage) showField(field string) bool {
+	return v.Fields == nil || slices.Contains(v.Fields, field)
 }
 
 // getWebsiteLink constructs the val.town website URL for a val
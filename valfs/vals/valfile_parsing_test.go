@@ -0,0 +1,285 @@
+package valfs_test
+
+import (
+	"context"
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	common "github.com/404wolf/valfs/common"
+	vals "github.com/404wolf/valfs/valfs/vals"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+var updateGolden = flag.Bool("update", false, "update the golden files in testdata")
+
+// fakeVal is an in memory val, so that val packages can be tested without
+// talking to val town
+type fakeVal struct {
+	name    string
+	valType string
+	code    string
+	privacy string
+	readme  string
+}
+
+func (v *fakeVal) Update(ctx context.Context) error { return nil }
+func (v *fakeVal) Load(ctx context.Context) error   { return nil }
+
+func (v *fakeVal) SetName(name string)       { v.name = name }
+func (v *fakeVal) SetValType(valType string) { v.valType = valType }
+func (v *fakeVal) SetCode(code string)       { v.code = code }
+func (v *fakeVal) SetPrivacy(privacy string) { v.privacy = privacy }
+func (v *fakeVal) SetReadme(readme string)   { v.readme = readme }
+
+func (v *fakeVal) GetId() string            { return "4f3aff9c-c54b-11ef-b3a1-e6cdfca9ef9f" }
+func (v *fakeVal) GetName() string          { return v.name }
+func (v *fakeVal) GetValType() vals.ValType { return vals.ValType(v.valType) }
+func (v *fakeVal) GetCode() string          { return v.code }
+func (v *fakeVal) GetPrivacy() string       { return v.privacy }
+func (v *fakeVal) GetReadme() string        { return v.readme }
+func (v *fakeVal) GetVersion() int32        { return 3 }
+func (v *fakeVal) GetVersionsLink() string  { return "https://api.val.town/v1/vals/test/versions" }
+func (v *fakeVal) GetModuleLink() string    { return "https://esm.town/v/wolf/test?v=3" }
+func (v *fakeVal) GetEndpointLink() string  { return "" }
+func (v *fakeVal) GetAuthorName() string    { return "wolf" }
+func (v *fakeVal) GetAuthorId() string      { return "a0b1c2d3-c54b-11ef-b3a1-e6cdfca9ef9f" }
+func (v *fakeVal) GetUrl() string           { return "https://www.val.town/v/wolf/test" }
+func (v *fakeVal) GetLikeCount() int32      { return 2 }
+func (v *fakeVal) GetReferenceCount() int32 { return 1 }
+func (v *fakeVal) GetCreatedAt() time.Time {
+	return time.Date(2024, 12, 28, 3, 12, 45, 0, time.UTC)
+}
+
+func newFakeVal(code string) *fakeVal {
+	return &fakeVal{
+		name:    "test",
+		valType: string(vals.Script),
+		code:    code,
+		privacy: vals.Unlisted,
+		readme:  "# Test\n\nA val for testing",
+	}
+}
+
+var roundTripCases = []struct {
+	name string
+	code string
+}{
+	{"simple", "console.log('hello');"},
+	{"trailing_newline", "console.log('hello');\n"},
+	{"trailing_newlines", "console.log('hello');\n\n\n"},
+	{"leading_newlines", "\n\nconsole.log('hello');\n"},
+	{"leading_comment", "// A comment\n/* and another */\nconsole.log('hello');\n"},
+	{"leading_whitespace", "  \t console.log('hello');"},
+	{"empty", ""},
+	{"crlf", "const a = 1;\r\nconsole.log(a);\r\n"},
+	{"bom", "\uFEFFconsole.log('hello');\n"},
+	{"bom_crlf", "\uFEFFconst a = 1;\r\nconsole.log(a);\r\n"},
+	{"inner_frontmatter", "/*---\nnot: frontmatter\n---*/\nconsole.log('hello');\n"},
+	{"unicode", "console.log('héllo wörld 👋');\n"},
+}
+
+// TestValPackageGolden checks that val packages render exactly as the golden
+// files in testdata, and that the golden files parse back to the same code
+func TestValPackageGolden(t *testing.T) {
+	for _, tc := range roundTripCases {
+		t.Run(tc.name, func(t *testing.T) {
+			goldenPath := filepath.Join("testdata", "roundtrip", tc.name+".golden")
+
+			valPackage := vals.NewValPackage(newFakeVal(tc.code), false, false)
+			text, err := valPackage.ToText()
+			require.NoError(t, err, "Failed to serialize val package")
+
+			if *updateGolden {
+				err = os.WriteFile(goldenPath, []byte(*text), 0644)
+				require.NoError(t, err, "Failed to update golden file")
+			}
+
+			golden, err := os.ReadFile(goldenPath)
+			require.NoError(t, err, "Failed to read golden file")
+			assert.Equal(t, string(golden), *text, "Text should match golden file")
+
+			parsedVal := newFakeVal("")
+			parsedPackage := vals.NewValPackage(parsedVal, false, false)
+			err = parsedPackage.UpdateVal(string(golden))
+			require.NoError(t, err, "Failed to parse golden file")
+			assert.Equal(t, tc.code, parsedVal.GetCode(), "Code should round trip")
+			assert.Equal(t, newFakeVal("").GetReadme(), parsedVal.GetReadme(), "Readme should round trip")
+		})
+	}
+}
+
+// TestValPackageRoundTrip checks that text written to a val file reads back
+// byte for byte, with and without shebangs
+func TestValPackageRoundTrip(t *testing.T) {
+	for _, executable := range []bool{false, true} {
+		for _, tc := range roundTripCases {
+			t.Run(tc.name, func(t *testing.T) {
+				val := newFakeVal(tc.code)
+				valPackage := vals.NewValPackage(val, false, executable)
+				text, err := valPackage.ToText()
+				require.NoError(t, err, "Failed to serialize val package")
+
+				err = valPackage.UpdateVal(*text)
+				require.NoError(t, err, "Failed to parse val package")
+				assert.Equal(t, tc.code, val.GetCode(), "Code should round trip")
+
+				again, err := valPackage.ToText()
+				require.NoError(t, err, "Failed to serialize val package again")
+				assert.Equal(t, *text, *again, "Text should round trip")
+			})
+		}
+	}
+}
+
+// TestValPackageShebang checks that executable val files start with a shebang
+// line that ends in a plain newline, before any byte order mark, whatever the
+// line endings of the code are
+func TestValPackageShebang(t *testing.T) {
+	for _, tc := range roundTripCases {
+		t.Run(tc.name, func(t *testing.T) {
+			val := newFakeVal(tc.code)
+			valPackage := vals.NewValPackage(val, false, true)
+			text, err := valPackage.ToText()
+			require.NoError(t, err, "Failed to serialize val package")
+
+			shebang, _, found := strings.Cut(*text, "\n")
+			require.True(t, found, "Text should have more than one line")
+			assert.True(t, strings.HasPrefix(shebang, "#!"), "Text should start with the shebang")
+			assert.NotContains(t, shebang, "\r", "Shebang shouldn't end in a carriage return")
+			assert.NotContains(t, shebang, "\uFEFF", "Byte order mark should come after the shebang")
+
+			err = valPackage.UpdateVal(*text)
+			require.NoError(t, err, "Failed to parse val package")
+			assert.Equal(t, tc.code, val.GetCode(), "Code should round trip")
+		})
+	}
+}
+
+// TestValPackageEmptyCode checks that the placeholder val town stores for
+// empty vals shows up as an empty val
+func TestValPackageEmptyCode(t *testing.T) {
+	val := newFakeVal(vals.EmptyValCode)
+	valPackage := vals.NewValPackage(val, false, false)
+	text, err := valPackage.ToText()
+	require.NoError(t, err, "Failed to serialize val package")
+
+	err = valPackage.UpdateVal(*text)
+	require.NoError(t, err, "Failed to parse val package")
+	assert.Equal(t, "", val.GetCode(), "Empty val should have no code")
+}
+
+// TestValPackageFrontmatterErrorLine checks that errors in the frontmatter
+// point at the line of the val file they are on
+func TestValPackageFrontmatterErrorLine(t *testing.T) {
+	common.Logger = zap.NewNop().Sugar()
+	contents := "#!/usr/bin/valfs\n\n/*---\nid: test\nprivacy: [unlisted\n---*/\n\nconsole.log('hello');\n"
+
+	valPackage := vals.NewValPackage(newFakeVal(""), false, false)
+	err := valPackage.UpdateVal(contents)
+	require.Error(t, err, "Broken frontmatter should not parse")
+
+	var frontmatterErr *vals.FrontmatterError
+	require.ErrorAs(t, err, &frontmatterErr, "Error should be located in the file")
+	assert.GreaterOrEqual(t, frontmatterErr.Line, 5, "Error should be on or after the broken line")
+	assert.LessOrEqual(t, frontmatterErr.Line, 6, "Error should be within the frontmatter")
+}
+
+// TestValPackageValidation checks that writes that change locked fields or
+// damage the frontmatter are refused
+func TestValPackageValidation(t *testing.T) {
+	common.Logger = zap.NewNop().Sugar()
+
+	golden, err := os.ReadFile(filepath.Join("testdata", "roundtrip", "simple.golden"))
+	require.NoError(t, err, "Failed to read golden file")
+
+	cases := []struct {
+		name  string
+		from  string
+		to    string
+		valid bool
+	}{
+		{"unchanged", "", "", true},
+		{"privacy", "privacy: unlisted", "privacy: public", true},
+		{"older version", "version: 3", "version: 2", true},
+		{"newer version", "version: 3", "version: 4", false},
+		{"invalid privacy", "privacy: unlisted", "privacy: secret", false},
+		{"changed id", "id: 4f3aff9c", "id: 00000000", false},
+		{"changed type", "type: script", "type: http", false},
+		{"changed author", "author: wolf", "author: someone", false},
+		{"changed link", "valtown: https://www.val.town/v/wolf/test", "valtown: https://example.com", false},
+		{"removed links", "links:\n    valtown: https://www.val.town/v/wolf/test # 🔒\n    esmModule: https://esm.town/v/wolf/test?v=3 # 🔒\n", "", false},
+		{"unknown key", "likes: 2", "likes: 2\nstars: 5", false},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			contents := strings.Replace(string(golden), tc.from, tc.to, 1)
+
+			valPackage := vals.NewValPackage(newFakeVal("console.log('hello');"), false, false)
+			err := valPackage.UpdateVal(contents)
+			if tc.valid {
+				assert.NoError(t, err, "Write should be accepted")
+			} else {
+				assert.Error(t, err, "Write should be refused")
+			}
+		})
+	}
+}
+
+func TestValPackageTruncate(t *testing.T) {
+	code := "console.log('hello');"
+	valPackage := vals.NewValPackage(newFakeVal(code), false, false)
+	text, err := valPackage.ToText()
+	require.NoError(t, err, "Failed to serialize val package")
+	headerLen := int64(len(*text) - len(code))
+
+	cases := []struct {
+		name string
+		size int64
+		code string
+	}{
+		{"to zero", 0, ""},
+		{"into frontmatter", headerLen / 2, ""},
+		{"to end of frontmatter", headerLen, ""},
+		{"into code", headerLen + 7, "console"},
+		{"unchanged", int64(len(*text)), code},
+		{"grown", int64(len(*text)) + 2, code + "\x00\x00"},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			parsedVal := newFakeVal(code)
+			parsedPackage := vals.NewValPackage(parsedVal, false, false)
+			err := parsedPackage.UpdateVal(vals.TruncateText(*text, tc.size))
+			require.NoError(t, err, "Truncated text should still parse")
+			assert.Equal(t, tc.code, parsedVal.GetCode())
+		})
+	}
+}
+
+func TestValFileMode(t *testing.T) {
+	cases := []struct {
+		privacy    string
+		executable bool
+		want       uint32
+	}{
+		{vals.Public, false, 0o644},
+		{vals.Public, true, 0o755},
+		{vals.Unlisted, false, 0o640},
+		{vals.Unlisted, true, 0o750},
+		{vals.Private, false, 0o600},
+		{vals.Private, true, 0o700},
+	}
+
+	for _, c := range cases {
+		mode := vals.ValFileMode(c.privacy, c.executable)
+		assert.Equal(t, c.want, mode&0o777, "mode of %s val", c.privacy)
+		assert.Equal(t, c.privacy, vals.PrivacyFromMode(mode))
+	}
+}
@@ -0,0 +1,157 @@
+package valfs
+
+import (
+	"context"
+	"syscall"
+
+	common "github.com/404wolf/valfs/common"
+	memfile "github.com/404wolf/valfs/valfs/memfile"
+	"github.com/hanwen/go-fuse/v2/fs"
+	"github.com/hanwen/go-fuse/v2/fuse"
+)
+
+// ValReadmeFileFlags defines the file permissions and type for readme files
+const ValReadmeFileFlags = syscall.S_IFREG | 0o666
+
+// ValReadmeFile represents a markdown file that holds the readme of a val
+type ValReadmeFile struct {
+	fs.Inode
+
+	ValFile *ValFile       // The val file whose readme this is
+	client  *common.Client // Client for API operations
+}
+
+// Interface compliance checks
+var _ = (fs.NodeSetattrer)((*ValReadmeFile)(nil))
+var _ = (fs.NodeGetattrer)((*ValReadmeFile)(nil))
+var _ = (fs.NodeWriter)((*ValReadmeFile)(nil))
+var _ = (fs.NodeOpener)((*ValReadmeFile)(nil))
+var _ = (fs.FileReader)((*ValReadmeFileHandle)(nil))
+
+// NewValReadmeFile creates a new readme file for the val of a val file
+func NewValReadmeFile(valFile *ValFile, client *common.Client) *ValReadmeFile {
+	return &ValReadmeFile{ValFile: valFile, client: client}
+}
+
+// ValReadmeFileHandle represents an open readme file handle
+type ValReadmeFileHandle struct {
+	ReadmeFile *ValReadmeFile
+}
+
+// Open handles opening the readme file and creates a new file handle
+func (f *ValReadmeFile) Open(ctx context.Context, openFlags uint32) (
+	fh fs.FileHandle,
+	fuseFlags uint32,
+	errno syscall.Errno,
+) {
+	err := f.ValFile.load(ctx)
+	if err != nil {
+		common.Logger.Error("Error fetching val", "error", err)
+		return nil, 0, common.ToErrno(err)
+	}
+
+	common.Logger.Info("Opening val readme file", "name", f.ValFile.Val.GetName())
+	return &ValReadmeFileHandle{ReadmeFile: f}, fuse.FOPEN_DIRECT_IO, syscall.F_OK
+}
+
+// Read handles reading the readme of the val
+func (fh *ValReadmeFileHandle) Read(
+	ctx context.Context,
+	dest []byte,
+	off int64,
+) (fuse.ReadResult, syscall.Errno) {
+	err := fh.ReadmeFile.ValFile.load(ctx)
+	if err != nil {
+		return nil, common.ToErrno(err)
+	}
+
+	readme := []byte(fh.ReadmeFile.ValFile.Val.GetReadme())
+	return memfile.ReadAt(readme, dest, off), syscall.F_OK
+}
+
+// Write handles writing data into the readme at the given offset
+func (f *ValReadmeFile) Write(
+	ctx context.Context,
+	fh fs.FileHandle,
+	data []byte,
+	off int64,
+) (written uint32, errno syscall.Errno) {
+	err := f.ValFile.load(ctx)
+	if err != nil {
+		return 0, common.ToErrno(err)
+	}
+
+	readme := []byte(f.ValFile.Val.GetReadme())
+	if end := off + int64(len(data)); end > int64(len(readme)) {
+		readme = append(readme, make([]byte, end-int64(len(readme)))...)
+	}
+	copy(readme[off:], data)
+
+	if errno := f.setReadme(ctx, string(readme)); errno != syscall.F_OK {
+		return 0, errno
+	}
+
+	return uint32(len(data)), syscall.F_OK
+}
+
+// Getattr retrieves the readme file attributes
+func (f *ValReadmeFile) Getattr(
+	ctx context.Context,
+	fh fs.FileHandle,
+	out *fuse.AttrOut,
+) syscall.Errno {
+	out.Size = uint64(len(f.ValFile.Val.GetReadme()))
+	out.Mode = ValReadmeFileFlags
+
+	modified := &f.ValFile.ModifiedAt
+	out.SetTimes(modified, modified, modified)
+
+	return syscall.F_OK
+}
+
+// Setattr sets the readme file attributes, truncating the readme if a new size
+// is requested
+func (f *ValReadmeFile) Setattr(
+	ctx context.Context,
+	fh fs.FileHandle,
+	in *fuse.SetAttrIn,
+	out *fuse.AttrOut,
+) syscall.Errno {
+	common.Logger.Info("Setting attributes for val readme file", "name", f.ValFile.Val.GetName())
+
+	if size, ok := in.GetSize(); ok {
+		err := f.ValFile.load(ctx)
+		if err != nil {
+			return common.ToErrno(err)
+		}
+
+		readme := []byte(f.ValFile.Val.GetReadme())
+		if int(size) < len(readme) {
+			readme = readme[:size]
+		} else {
+			readme = append(readme, make([]byte, int(size)-len(readme))...)
+		}
+
+		if errno := f.setReadme(ctx, string(readme)); errno != syscall.F_OK {
+			return errno
+		}
+	}
+
+	return f.Getattr(ctx, fh, out)
+}
+
+// setReadme updates the readme of the val on val town, holding it back like
+// writes to the val file if writes are debounced
+func (f *ValReadmeFile) setReadme(ctx context.Context, readme string) syscall.Errno {
+	// Drafts only hold val files, so readme changes would go live right away
+	if f.ValFile.drafts() != nil {
+		common.Logger.Warnf("Cannot change readme of val %s in draft mode", f.ValFile.Val.GetId())
+		return syscall.EPERM
+	}
+
+	f.ValFile.valMutex.Lock()
+	f.ValFile.Val.SetReadme(readme)
+	f.ValFile.valMutex.Unlock()
+
+	return f.ValFile.push(ctx)
+}
@@ -17,5 +17,5 @@ func getCurrentExecutablePath() string {
 
 // Prepend a shebang that defines how to execute it
 func AffixShebang(code string) string {
-	return "#!" + getCurrentExecutablePath() + "\n\n" + code
+	return "#!" + getCurrentExecutablePath() + frontmatterSeparator + code
 }
@@ -2,6 +2,7 @@ package valfs
 
 import (
 	"fmt"
+	"slices"
 	"strings"
 
 	_ "embed"
@@ -28,6 +29,9 @@ const (
 	Private  = "private"
 )
 
+// The privacies that a val can have
+var Privacies = []string{Public, Unlisted, Private}
+
 var abbreviate = map[ValType]string{
 	Unknown:  "U",
 	HTTP:     "H",
@@ -50,6 +54,8 @@ var unabbreviate = map[string]ValType{
 }
 
 const ValExtension = "tsx"
+const ReadmeExtension = "README.md"
+const ErrorExtension = "error"
 const DefaultPrivacy = Unlisted
 const DefaultType = Script
 
@@ -105,4 +111,84 @@ func ConstructFilename(baseName string, valType ValType) string {
 	return fmt.Sprintf("%s.%s.tsx", baseName, abbreviate[valType])
 }
 
+// The ways that val files can be laid out in the vals directory
+const (
+	LayoutFlat   = "flat"    // All vals in one directory, as name.H.tsx
+	LayoutByType = "by-type" // Vals in a directory per type, as http/name.tsx
+)
+
+// The layouts that val files can be laid out in
+var Layouts = []string{LayoutFlat, LayoutByType}
+
+// The directories that vals of each type are in, for the by-type layout
+var typeDirs = map[ValType]string{
+	HTTP:     "http",
+	Script:   "script",
+	Email:    "email",
+	Cron:     "cron",
+	Interval: "cron",
+}
+
+// TypeDirs lists the directories of the by-type layout
+var TypeDirs = []string{"http", "script", "email", "cron"}
+
+// Takes a ValType and returns the directory that vals of that type are in,
+// for the by-type layout
+func TypeDir(valType ValType) string {
+	return typeDirs[valType]
+}
+
+// Takes a base name and ValType and returns the path of the val file
+// relative to the vals directory, for a layout
+func ConstructLayoutPath(layout string, baseName string, valType ValType) string {
+	if layout == LayoutByType {
+		return TypeDir(valType) + "/" + ConstructLayoutFilename(layout, baseName, valType)
+	}
+	return ConstructLayoutFilename(layout, baseName, valType)
+}
+
+// Takes a base name and ValType and returns the filename of the val file, for
+// a layout
+func ConstructLayoutFilename(layout string, baseName string, valType ValType) string {
+	if layout == LayoutByType {
+		return fmt.Sprintf("%s.%s", baseName, ValExtension)
+	}
+	return ConstructFilename(baseName, valType)
+}
+
+// Takes the directory a val file is in, relative to the vals directory, and
+// its filename and returns the corresponding name and ValType, for a layout
+func ExtractFromLayoutFilename(layout string, dir string, filename string) (string, ValType) {
+	if layout != LayoutByType {
+		return ExtractFromFilename(filename)
+	}
+
+	name, ok := strings.CutSuffix(filename, "."+ValExtension)
+	if !ok || !slices.Contains(TypeDirs, dir) {
+		return filename, Unknown
+	}
+	return name, unabbreviate[dir]
+}
+
+// Takes a base name and returns the filename of the val's readme file
+func ConstructReadmeFilename(baseName string) string {
+	return fmt.Sprintf("%s.%s", baseName, ReadmeExtension)
+}
+
+// Whether a filename is that of a val's readme file
+func IsReadmeFilename(filename string) bool {
+	return strings.HasSuffix(filename, "."+ReadmeExtension)
+}
+
+// Takes the filename of a val file and returns the filename of the file that
+// holds its last error
+func ConstructErrorFilename(valFilename string) string {
+	return fmt.Sprintf("%s.%s", valFilename, ErrorExtension)
+}
+
+// Whether a filename is that of a val's error file
+func IsErrorFilename(filename string) bool {
+	return strings.HasSuffix(filename, "."+ErrorExtension)
+}
+
 var ValFileMeta = fs.StableAttr{Mode: fuse.S_IFREG | 0777}
@@ -0,0 +1,37 @@
+package valfs_test
+
+import (
+	"testing"
+
+	vals "github.com/404wolf/valfs/valfs/vals"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLayoutFilenames(t *testing.T) {
+	cases := []struct {
+		layout   string
+		path     string
+		dir      string
+		filename string
+		name     string
+		valType  vals.ValType
+	}{
+		{vals.LayoutFlat, "api.H.tsx", "", "api.H.tsx", "api", vals.HTTP},
+		{vals.LayoutFlat, "my.script.S.tsx", "", "my.script.S.tsx", "my.script", vals.Script},
+		{vals.LayoutByType, "http/api.tsx", "http", "api.tsx", "api", vals.HTTP},
+		{vals.LayoutByType, "email/inbox.tsx", "email", "inbox.tsx", "inbox", vals.Email},
+	}
+
+	for _, c := range cases {
+		name, valType := vals.ExtractFromLayoutFilename(c.layout, c.dir, c.filename)
+		assert.Equal(t, c.name, name)
+		assert.Equal(t, c.valType, valType)
+		assert.Equal(t, c.path, vals.ConstructLayoutPath(c.layout, name, valType))
+	}
+
+	_, valType := vals.ExtractFromLayoutFilename(vals.LayoutByType, "", "api.tsx")
+	assert.Equal(t, vals.Unknown, valType, "Vals outside of type directories have no type")
+
+	_, valType = vals.ExtractFromLayoutFilename(vals.LayoutByType, "http", "api.H.ts")
+	assert.Equal(t, vals.Unknown, valType, "Val files must end in .tsx")
+}
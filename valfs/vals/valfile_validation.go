@@ -0,0 +1,146 @@
+package valfs
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// validateFrontmatter checks that frontmatter written to a val file only
+// changes fields that can be changed, and that it changes them to valid
+// values. Fields marked with a 🔒 must be left as they are, and may not be
+// removed.
+func (v *ValPackage) validateFrontmatter(contents string, meta *valPackageFrontmatter) error {
+	if meta.Privacy != nil && !slices.Contains(Privacies, *meta.Privacy) {
+		return newFieldError(contents, "privacy", fmt.Sprintf(
+			"privacy must be one of %s, not %q",
+			strings.Join(Privacies, ", "),
+			*meta.Privacy,
+		))
+	}
+
+	if meta.ReadMe != nil && v.ReadmeFiles {
+		return newFieldError(contents, "readme", fmt.Sprintf(
+			"the readme is edited in %s",
+			ConstructReadmeFilename(v.Val.GetName()),
+		))
+	}
+
+	expected := v.getFrontmatter()
+
+	if err := checkLockedField(contents, "id", expected.Id, meta.Id); err != nil {
+		return err
+	}
+
+	// The rest of the locked fields are only known once the val is loaded. If
+	// the author isn't known then nothing else is either (see Getattr).
+	if v.Val.GetAuthorId() == "" {
+		return nil
+	}
+
+	// The version goes up on every write, so an older version just means the
+	// file was not reopened since
+	if expected.Version != nil {
+		if meta.Version == nil {
+			return newFieldError(contents, "version", "version is locked and cannot be removed")
+		}
+		if *meta.Version > *expected.Version {
+			return newFieldError(contents, "version", "version is locked and cannot be changed")
+		}
+	}
+
+	if err := checkLockedField(contents, "type", expected.Type, meta.Type); err != nil {
+		return err
+	}
+	if err := checkLockedField(contents, "author", expected.Author, meta.Author); err != nil {
+		return err
+	}
+	if err := checkLockedField(contents, "createdAt", expected.CreatedAt, meta.CreatedAt); err != nil {
+		return err
+	}
+
+	// Likes and references change on their own, so they may be outdated
+	if expected.Likes != nil && meta.Likes == nil {
+		return newFieldError(contents, "likes", "likes is locked and cannot be removed")
+	}
+	if expected.References != nil && meta.References == nil {
+		return newFieldError(contents, "references", "references is locked and cannot be removed")
+	}
+
+	if expected.Links != nil {
+		if meta.Links == nil {
+			return newFieldError(contents, "links", "links is locked and cannot be removed")
+		}
+		return validateLinks(contents, expected.Links, meta.Links)
+	}
+
+	return nil
+}
+
+// validateLinks checks that none of the links of a val were changed
+func validateLinks(contents string, expected, actual *valPackageFrontmatterLinks) error {
+	if err := checkLockedField(contents, "valtown", &expected.Valtown, &actual.Valtown); err != nil {
+		return err
+	}
+
+	// The module link has the version in it, which may be outdated
+	expectedModule := strings.Split(expected.Module, "?")[0]
+	actualModule := strings.Split(actual.Module, "?")[0]
+	if err := checkLockedField(contents, "esmModule", &expectedModule, &actualModule); err != nil {
+		return err
+	}
+
+	if err := checkLockedField(contents, "deployment", expected.Endpoint, actual.Endpoint); err != nil {
+		return err
+	}
+	if actual.Endpoint != nil && expected.Endpoint == nil {
+		return newFieldError(contents, "deployment", "deployment is locked and cannot be added")
+	}
+
+	if err := checkLockedField(contents, "email", expected.Email, actual.Email); err != nil {
+		return err
+	}
+	if actual.Email != nil && expected.Email == nil {
+		return newFieldError(contents, "email", "email is locked and cannot be added")
+	}
+
+	return nil
+}
+
+// checkLockedField checks that a locked field that is shown in the
+// frontmatter is still there, and still has the same value
+func checkLockedField[T comparable](contents, key string, expected, actual *T) error {
+	if expected == nil {
+		return nil
+	}
+	if actual == nil {
+		return newFieldError(contents, key, key+" is locked and cannot be removed")
+	}
+	if *expected != *actual {
+		return newFieldError(contents, key, key+" is locked and cannot be changed")
+	}
+	return nil
+}
+
+// newFieldError creates an error for a field of the frontmatter, located at
+// the line the field is on if it is there
+func newFieldError(contents, key, message string) error {
+	frontmatter := contents
+	if end := strings.Index(contents, "---*/"); end != -1 {
+		frontmatter = contents[:end]
+	}
+
+	keyRe := regexp.MustCompile(`(?m)^[ \t]*` + regexp.QuoteMeta(key) + `[ \t]*:`)
+	location := keyRe.FindStringIndex(frontmatter)
+	if location == nil {
+		return fmt.Errorf("invalid frontmatter: %s", message)
+	}
+
+	lineStart := strings.LastIndex(frontmatter[:location[0]], "\n") + 1
+	return &FrontmatterError{
+		Line:    strings.Count(frontmatter[:location[0]], "\n") + 1,
+		Column:  location[0] - lineStart + 1,
+		Message: message,
+	}
+}
@@ -0,0 +1,96 @@
+package valfs
+
+import (
+	"context"
+	"path"
+	"regexp"
+	"syscall"
+
+	common "github.com/404wolf/valfs/common"
+	"github.com/hanwen/go-fuse/v2/fs"
+	"github.com/hanwen/go-fuse/v2/fuse"
+)
+
+// Matches the ids of vals
+var valIdRe = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+
+// ValsByIdDir is a directory where any val can be found by its id. Our own
+// vals are symlinks to their val files, and other vals are read only files.
+// Nothing is listed, entries only show up when they are looked up.
+type ValsByIdDir struct {
+	fs.Inode
+
+	client  *common.Client
+	valsDir ValsContainer // Where our own vals are, or nil if there is none
+}
+
+var _ = (fs.NodeLookuper)((*ValsByIdDir)(nil))
+
+// NewValsByIdDir creates a directory that looks up vals by their id
+func NewValsByIdDir(
+	ctx context.Context,
+	parent *fs.Inode,
+	client *common.Client,
+	valsDir ValsContainer,
+) *ValsByIdDir {
+	byIdDir := &ValsByIdDir{client: client, valsDir: valsDir}
+	parent.NewPersistentInode(ctx, byIdDir, fs.StableAttr{Mode: syscall.S_IFDIR | 0555})
+	return byIdDir
+}
+
+// Lookup resolves a val id to the val with that id
+func (c *ValsByIdDir) Lookup(
+	ctx context.Context,
+	name string,
+	out *fuse.EntryOut,
+) (*fs.Inode, syscall.Errno) {
+	if !valIdRe.MatchString(name) {
+		return nil, syscall.ENOENT
+	}
+
+	if target, ok := c.ownValTarget(name); ok {
+		common.Logger.Infof("Linking val %s to %s", name, target)
+		symlink := &fs.MemSymlink{Data: []byte(target)}
+		out.Attr.Mode = syscall.S_IFLNK | 0o777
+		out.Attr.Size = uint64(len(target))
+		return c.NewInode(ctx, symlink, fs.StableAttr{Mode: syscall.S_IFLNK}), syscall.F_OK
+	}
+
+	val := ValDirValOf(c.client.APIClient, name)
+	err := val.Load(ctx)
+	if err != nil {
+		common.Logger.Errorf("Error looking up val %s: %v", name, err)
+		return nil, common.ToErrno(err)
+	}
+
+	common.Logger.Infof("Found val %s by %s", name, val.GetAuthorName())
+	valFile := NewReadOnlyValFile(val, c.client)
+	attrOut := fuse.AttrOut{}
+	if errno := valFile.Getattr(ctx, nil, &attrOut); errno != syscall.F_OK {
+		return nil, errno
+	}
+	out.Attr = attrOut.Attr
+
+	return c.NewInode(ctx, valFile, fs.StableAttr{Mode: syscall.S_IFREG}), syscall.F_OK
+}
+
+// ownValTarget returns the relative path from the directory to the val file
+// of one of our own vals, and whether the val is one of ours
+func (c *ValsByIdDir) ownValTarget(valId string) (string, bool) {
+	if c.valsDir == nil {
+		return "", false
+	}
+
+	valFile, ok := c.valsDir.ValFileById(valId)
+	if !ok {
+		return "", false
+	}
+
+	name, dir := valFile.Parent()
+	if dir == nil {
+		return "", false
+	}
+	valsInode := c.valsDir.GetInode()
+	valPath := path.Join(dir.Path(valsInode), name)
+	return path.Join("..", valsInode.Path(nil), valPath), true
+}
@@ -48,4 +48,14 @@ type ValsContainer interface {
 	Refresh(ctx context.Context) error
 	StartAutoRefresh(ctx context.Context, interval time.Duration)
 	StopAutoRefresh()
+	OnRefresh(hook func(ctx context.Context)) // Run hook after every refresh
+
+	// All the val files in the container
+	ValFiles() []*ValFile
+
+	// The val file of the val with the given id, if it is in the container
+	ValFileById(valId string) (*ValFile, bool)
+
+	// Push any writes that are being held back to val town
+	FlushWrites(ctx context.Context)
 }
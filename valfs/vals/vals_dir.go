@@ -2,6 +2,9 @@ package valfs
 
 import (
 	"context"
+	"path"
+	"slices"
+	"sync"
 	"syscall"
 	"time"
 
@@ -20,15 +23,22 @@ type ValsDir struct {
 	client   *common.Client
 	config   common.RefresherConfig
 	stopChan chan struct{}
+	folders  *FolderStore // Folders that vals are grouped into, if enabled
+
+	valFilesMutex sync.RWMutex        // Guards valFiles
+	valFiles      map[string]*ValFile // The val files in the dir, by val id
+
+	refreshHooksMutex sync.Mutex                  // Guards refreshHooks
+	refreshHooks      []func(ctx context.Context) // Run after every refresh
 }
 
 var _ = (fs.NodeRenamer)((*ValsDir)(nil))
 var _ = (fs.NodeCreater)((*ValsDir)(nil))
 var _ = (fs.NodeUnlinker)((*ValsDir)(nil))
+var _ = (fs.NodeMkdirer)((*ValsDir)(nil))
+var _ = (fs.NodeRmdirer)((*ValsDir)(nil))
 var _ = (ValsContainer)((*ValsDir)(nil))
 
-var previousValIds = make(map[string]*ValFile)
-
 // Set up background refresh of vals and retreive an auto updating folder of
 // val files
 func NewValsDir(
@@ -41,12 +51,31 @@ func NewValsDir(
 		client:   client,
 		config:   common.RefresherConfig{LookupCap: 99},
 		stopChan: nil,
+		valFiles: make(map[string]*ValFile),
 	}
 
 	// Add the inode to the parent
 	attrs := fs.StableAttr{Mode: syscall.S_IFDIR | 0555}
 	parent.NewPersistentInode(ctx, valsDir, attrs)
 
+	if client.Config.Layout == LayoutByType {
+		for _, typeDir := range TypeDirs {
+			valsDir.folderInode(ctx, typeDir)
+		}
+	}
+
+	if client.Config.Folders {
+		folders, err := NewFolderStore(client.Config.FoldersFile)
+		if err != nil {
+			common.Logger.Errorf("Error loading folders, folders are disabled: %v", err)
+		} else {
+			valsDir.folders = folders
+			for _, folder := range folders.Dirs() {
+				valsDir.folderInode(ctx, folder)
+			}
+		}
+	}
+
 	// Initial refresh
 	common.Logger.Info("Performing initial refresh of ValsDir")
 	valsDir.Refresh(ctx)
@@ -68,9 +97,9 @@ func (c *ValsDir) GetClient() *common.Client {
 	return c.client
 }
 
-// GetClient returns whether the vals dir supports subdirectories
+// SupportsDirs returns whether the vals dir supports subdirectories
 func (c *ValsDir) SupportsDirs() bool {
-	return false
+	return c.folders != nil
 }
 
 // GetInode returns the inode associated with this ValsContainer
@@ -80,8 +109,36 @@ func (c *ValsDir) GetInode() *fs.Inode {
 
 // Handle deletion of a file by also deleting the val
 func (c *ValsDir) Unlink(ctx context.Context, name string) syscall.Errno {
+	return c.unlink(ctx, &c.Inode, name)
+}
+
+// unlink deletes the val of a file in dir, which is the vals dir or one of its
+// folders
+func (c *ValsDir) unlink(ctx context.Context, dir *fs.Inode, name string) syscall.Errno {
 	common.Logger.Infof("Unlink request received for val: %s", name)
-	child := c.GetChild(name)
+	if IsReadmeFilename(name) {
+		common.Logger.Warnf("Unlink failed: %s is a readme, clear it instead", name)
+		return syscall.EPERM
+	}
+
+	// Removing an error file just dismisses the error
+	if IsErrorFilename(name) {
+		if child := dir.GetChild(name); child != nil {
+			if errorFile, ok := child.Operations().(*ValErrorFile); ok {
+				errorFile.ValFile.clearError()
+			}
+		}
+		common.Logger.Infof("Dismissed error file %s", name)
+		return syscall.F_OK
+	}
+
+	// Drafts only hold changes to vals, so deleting one would go live
+	if c.client.Config.Drafts {
+		common.Logger.Warnf("Cannot delete val %s in draft mode", name)
+		return syscall.EPERM
+	}
+
+	child := dir.GetChild(name)
 	if child == nil {
 		common.Logger.Warnf("Unlink failed: val %s not found", name)
 		return syscall.ENOENT
@@ -97,9 +154,18 @@ func (c *ValsDir) Unlink(ctx context.Context, name string) syscall.Errno {
 	err := DeleteValDirVal(ctx, c.client.APIClient, valFile.Val.GetId())
 	if err != nil {
 		common.Logger.Errorf("Error deleting val %s: %v", name, err)
-		return syscall.EIO
+		return common.ToErrno(err)
+	}
+	common.Logger.Infof("Successfully deleted val %s (ID: %s)", name, valFile.Val.GetId())
+
+	valName, _ := c.extractFromFilename(dir, name)
+	dir.RmChild(ConstructReadmeFilename(valName), ConstructErrorFilename(name))
+
+	if c.folders != nil {
+		if err := c.folders.RemoveVal(valFile.Val.GetId()); err != nil {
+			common.Logger.Errorf("Error forgetting folder of val %s: %v", name, err)
+		}
 	}
-	common.Logger.Infof("Successfully deleted val %s (ID: %s)", valFile.Val.GetId())
 
 	return 0
 }
@@ -112,9 +178,32 @@ func (c *ValsDir) Create(
 	mode uint32,
 	entryOut *fuse.EntryOut,
 ) (inode *fs.Inode, fh fs.FileHandle, fuseFlags uint32, code syscall.Errno) {
-	common.Logger.Infof("Create request received for: %s (flags: %d, mode: %d)", name, flags, mode)
+	return c.create(ctx, &c.Inode, "", name, flags)
+}
 
-	valName, valType := ExtractFromFilename(name)
+// create makes a new val for a file created in dir, which is the vals dir or
+// the folder with the given path
+func (c *ValsDir) create(
+	ctx context.Context,
+	dir *fs.Inode,
+	folder string,
+	name string,
+	flags uint32,
+) (inode *fs.Inode, fh fs.FileHandle, fuseFlags uint32, code syscall.Errno) {
+	common.Logger.Infof("Create request received for: %s (flags: %d)", name, flags)
+
+	if IsReadmeFilename(name) || IsErrorFilename(name) {
+		common.Logger.Errorf("Create failed: %s does not belong to a val", name)
+		return nil, nil, 0, syscall.EPERM
+	}
+
+	// Drafts only hold changes to vals, so creating one would go live
+	if c.client.Config.Drafts {
+		common.Logger.Warnf("Cannot create val %s in draft mode", name)
+		return nil, nil, 0, syscall.EPERM
+	}
+
+	valName, valType := c.extractFromFilename(dir, name)
 	if valType == Unknown {
 		common.Logger.Errorf("Create failed: unknown val type for file %s", name)
 		return nil, nil, 0, syscall.EINVAL
@@ -126,7 +215,7 @@ func (c *ValsDir) Create(
 	val, err := CreateValDirVal(ctx, c.client.APIClient, valType, string(templateCode), valName, DefaultPrivacy)
 	if err != nil {
 		common.Logger.Errorf("API error creating val %s: %v", name, err)
-		return nil, nil, 0, syscall.EIO
+		return nil, nil, 0, common.ToErrno(err)
 	}
 
 	common.Logger.Info("Creating val file")
@@ -141,12 +230,25 @@ func (c *ValsDir) Create(
 		valFile,
 		fs.StableAttr{Mode: syscall.S_IFREG, Ino: 0})
 
+	valFile.valMutex.Lock()
+	valFile.fresh = true
+	valFile.valMutex.Unlock()
+
 	fileHandle, _, _ := valFile.Open(ctx, flags)
+	c.addReadmeFile(ctx, dir, valFile)
+	c.setValFile(val.GetId(), valFile)
 	valFile.ModifiedNow()
 
-	waitThenMaybeDenoCache(name, c.client)
+	if c.folders != nil && folder != "" {
+		if err := c.folders.SetFolder(val.GetId(), folder); err != nil {
+			common.Logger.Errorf("Error saving folder of val %s: %v", name, err)
+		}
+	}
+
+	// The val file isn't in dir until this returns, so its path comes from dir
+	waitThenMaybeDenoCache(path.Join(dir.Path(&c.Inode), name), c.client)
 
-	return newInode, &fileHandle, fuse.FOPEN_DIRECT_IO, syscall.F_OK
+	return newInode, fileHandle, fuse.FOPEN_DIRECT_IO, syscall.F_OK
 }
 
 // Rename a val, and change the name in valtown
@@ -156,39 +258,99 @@ func (c *ValsDir) Rename(
 	newParent fs.InodeEmbedder,
 	newName string,
 	flags uint32,
+) syscall.Errno {
+	return c.rename(ctx, &c.Inode, oldName, newParent, newName)
+}
+
+// rename renames a val file in dir, which is the vals dir or one of its
+// folders, and may move it into another folder
+func (c *ValsDir) rename(
+	ctx context.Context,
+	dir *fs.Inode,
+	oldName string,
+	newParent fs.InodeEmbedder,
+	newName string,
 ) syscall.Errno {
 	common.Logger.Infof("Rename request from %s to %s", oldName, newName)
 
-	if newParent.EmbeddedInode().StableAttr().Ino != c.Inode.StableAttr().Ino {
+	newFolder, ok := c.folderPath(newParent)
+	if !ok {
 		common.Logger.Warn("Cannot move val out of the `vals` directory")
 		return syscall.EINVAL
 	}
+	newDir := newParent.EmbeddedInode()
 
-	valName, valType := ExtractFromFilename(newName)
+	if child := dir.GetChild(oldName); child != nil {
+		if _, isFolder := child.Operations().(*ValsFolder); isFolder {
+			return c.renameFolder(child, newDir, newFolder, newName)
+		}
+	}
+
+	if IsReadmeFilename(oldName) || IsReadmeFilename(newName) ||
+		IsErrorFilename(oldName) || IsErrorFilename(newName) {
+		common.Logger.Warn("Readme and error files are renamed along with their val")
+		return syscall.EPERM
+	}
+
+	valName, valType := c.extractFromFilename(newDir, newName)
 	if valType == Unknown {
 		common.Logger.Errorf("Invalid val type in new name: %s", newName)
 		return syscall.EINVAL
 	}
 
-	if c.GetChild(newName) != nil {
+	if newDir.GetChild(newName) != nil {
 		common.Logger.Warnf("Destination file already exists: %s", newName)
 		return syscall.EEXIST
 	}
 
-	inode := c.GetChild(oldName)
+	inode := dir.GetChild(oldName)
 	if inode == nil {
 		common.Logger.Warnf("Source file not found: %s", oldName)
 		return syscall.ENOENT
 	}
-	valFile := inode.Operations().(*ValFile)
+	valFile, ok := inode.Operations().(*ValFile)
+	if !ok {
+		common.Logger.Errorf("Rename failed: %s is not a ValFile", oldName)
+		return syscall.EINVAL
+	}
+	oldValName, oldValType := c.extractFromFilename(dir, oldName)
+
+	// Moving a val between folders is only local, but renaming it or changing
+	// its type would go live even in draft mode
+	if valName != oldValName || valType != oldValType {
+		if c.client.Config.Drafts {
+			common.Logger.Warnf("Cannot rename val %s to %s in draft mode", oldName, newName)
+			return syscall.EPERM
+		}
 
-	common.Logger.Infof("Updating val %s to new name %s and type %s", oldName, valName, valType)
-	valFile.Val.SetName(valName)
-	valFile.Val.SetValType(string(valType))
-	err := valFile.Val.Update(ctx)
-	if err != nil {
-		common.Logger.Errorf("Error updating val %s: %v", oldName, err)
-		return syscall.EIO
+		common.Logger.Infof("Updating val %s to new name %s and type %s", oldName, valName, valType)
+		valFile.Val.SetName(valName)
+		valFile.Val.SetValType(string(valType))
+		err := valFile.Val.Update(ctx)
+		if err != nil {
+			common.Logger.Errorf("Error updating val %s: %v", oldName, err)
+			return common.ToErrno(err)
+		}
+	}
+
+	dir.MvChild(
+		ConstructReadmeFilename(oldValName),
+		newDir,
+		ConstructReadmeFilename(valName),
+		true,
+	)
+	dir.MvChild(
+		ConstructErrorFilename(oldName),
+		newDir,
+		ConstructErrorFilename(newName),
+		true,
+	)
+
+	if c.folders != nil {
+		if err := c.folders.SetFolder(valFile.Val.GetId(), newFolder); err != nil {
+			common.Logger.Errorf("Error saving folder of val %s: %v", newName, err)
+			return syscall.EIO
+		}
 	}
 
 	common.Logger.Infof("Successfully renamed val from %s to %s", oldName, newName)
@@ -211,7 +373,7 @@ func (c *ValsDir) Refresh(ctx context.Context) error {
 	common.Logger.Infof("Fetched %d vals for refresh", len(newVals))
 
 	for _, newVal := range newVals {
-		prevValFile, exists := previousValIds[newVal.GetId()]
+		prevValFile, exists := c.valFile(newVal.GetId())
 
 		if !exists {
 			common.Logger.Infof("Creating new val file for %s", newVal.GetId())
@@ -220,35 +382,145 @@ func (c *ValsDir) Refresh(ctx context.Context) error {
 				common.Logger.Errorf("Error creating val file for %s: %v", newVal.GetId(), err)
 				return err
 			}
-			filename := ConstructFilename(newVal.GetName(), newVal.GetValType())
+			filename := c.filename(newVal)
+			dir := c.folderInode(ctx, c.folderOf(newVal))
 			c.NewPersistentInode(ctx, valFile, fs.StableAttr{Mode: syscall.S_IFREG, Ino: 0})
-			c.AddChild(filename, &valFile.Inode, true)
-			previousValIds[newVal.GetId()] = valFile
+			dir.AddChild(filename, &valFile.Inode, true)
+			c.addReadmeFile(ctx, dir, valFile)
+			c.setValFile(newVal.GetId(), valFile)
 			common.Logger.Infof("Added val %s, found fresh on valtown", newVal.GetId())
+			continue
 		}
 
-		if exists && newVal.GetVersion() > prevValFile.Val.GetVersion() {
-			common.Logger.Infof("Updating existing val %s to version %d", newVal.GetId(), newVal.GetVersion())
+		if prevValFile.HasPendingWrite() {
+			common.Logger.Infof("Skipping update of val %s, it has pending writes", newVal.GetId())
+			continue
+		}
+
+		prevValFile.valMutex.Lock()
+		newer := newVal.GetVersion() > prevValFile.Val.GetVersion()
+		if newer {
 			prevValFile.Val = newVal
+		}
+		prevValFile.valMutex.Unlock()
+
+		if newer {
+			common.Logger.Infof("Updating existing val %s to version %d", newVal.GetId(), newVal.GetVersion())
 			prevValFile.ModifiedNow()
 			prevValFile.EmbeddedInode().Root().NotifyContent(0, 0)
 			common.Logger.Infof("Updated val %s, found newer on valtown", newVal.GetId())
 		}
 	}
 
-	for _, oldVal := range previousValIds {
-		if _, exists := newValsIdsToVals[oldVal.Val.GetId()]; !exists {
-			filename := ConstructFilename(oldVal.Val.GetName(), oldVal.Val.GetValType())
+	for _, oldVal := range c.valFilesSnapshot() {
+		var valId, valName, filename string
+		oldVal.withVal(func(val Val) {
+			valId, valName, filename = val.GetId(), val.GetName(), c.filename(val)
+		})
+
+		if _, exists := newValsIdsToVals[valId]; !exists {
 			common.Logger.Infof("Removing val %s as it's no longer found on valtown", filename)
-			c.RmChild(filename)
-			delete(previousValIds, oldVal.Val.GetId())
-			common.Logger.Infof("Removed val %s no longer found on valtown", oldVal.Val.GetId())
+			if _, dir := oldVal.Parent(); dir != nil {
+				dir.RmChild(filename, ConstructReadmeFilename(valName), ConstructErrorFilename(filename))
+			}
+			if c.folders != nil {
+				c.folders.RemoveVal(valId)
+			}
+			c.removeValFile(valId)
+			common.Logger.Infof("Removed val %s no longer found on valtown", valId)
 		}
 	}
 
+	c.refreshHooksMutex.Lock()
+	hooks := slices.Clone(c.refreshHooks)
+	c.refreshHooksMutex.Unlock()
+
+	for _, hook := range hooks {
+		hook(ctx)
+	}
+
 	return nil
 }
 
+// OnRefresh registers a hook that is run after every refresh, once the val
+// files are up to date
+func (c *ValsDir) OnRefresh(hook func(ctx context.Context)) {
+	c.refreshHooksMutex.Lock()
+	defer c.refreshHooksMutex.Unlock()
+	c.refreshHooks = append(c.refreshHooks, hook)
+}
+
+// ValFiles returns a snapshot of all the val files in the vals dir. Their
+// vals can be replaced by refreshes, so read them with withVal.
+func (c *ValsDir) ValFiles() []*ValFile {
+	return c.valFilesSnapshot()
+}
+
+// ValFileById returns the val file of the val with the given id, and whether
+// the val is in the vals dir
+func (c *ValsDir) ValFileById(valId string) (*ValFile, bool) {
+	return c.valFile(valId)
+}
+
+// valFilesSnapshot copies the val files, so that they can be gone through
+// while vals are created and refreshed
+func (c *ValsDir) valFilesSnapshot() []*ValFile {
+	c.valFilesMutex.RLock()
+	defer c.valFilesMutex.RUnlock()
+
+	valFiles := make([]*ValFile, 0, len(c.valFiles))
+	for _, valFile := range c.valFiles {
+		valFiles = append(valFiles, valFile)
+	}
+	return valFiles
+}
+
+// valFile returns the val file of the val with the given id
+func (c *ValsDir) valFile(valId string) (*ValFile, bool) {
+	c.valFilesMutex.RLock()
+	defer c.valFilesMutex.RUnlock()
+	valFile, exists := c.valFiles[valId]
+	return valFile, exists
+}
+
+// setValFile records the val file of the val with the given id
+func (c *ValsDir) setValFile(valId string, valFile *ValFile) {
+	c.valFilesMutex.Lock()
+	defer c.valFilesMutex.Unlock()
+	c.valFiles[valId] = valFile
+}
+
+// removeValFile forgets the val file of the val with the given id
+func (c *ValsDir) removeValFile(valId string) {
+	c.valFilesMutex.Lock()
+	defer c.valFilesMutex.Unlock()
+	delete(c.valFiles, valId)
+}
+
+// addReadmeFile adds the readme file of a val file next to it, if readmes are
+// configured to be separate files
+func (c *ValsDir) addReadmeFile(ctx context.Context, dir *fs.Inode, valFile *ValFile) {
+	if !c.client.Config.ReadmeFiles {
+		return
+	}
+
+	readmeFile := NewValReadmeFile(valFile, c.client)
+	filename := ConstructReadmeFilename(valFile.Val.GetName())
+	c.NewPersistentInode(ctx, readmeFile, fs.StableAttr{Mode: syscall.S_IFREG, Ino: 0})
+	dir.AddChild(filename, &readmeFile.Inode, true)
+}
+
+// FlushWrites pushes the debounced writes of all the vals to val town
+func (c *ValsDir) FlushWrites(ctx context.Context) {
+	common.Logger.Info("Flushing pending writes")
+
+	// Flushing makes requests, so it happens on a copy of the val files rather
+	// than with them locked
+	for _, valFile := range c.valFilesSnapshot() {
+		valFile.FlushWrite(ctx)
+	}
+}
+
 // StartAutoRefresh begins automatic refreshing of the vals container
 func (c *ValsDir) StartAutoRefresh(ctx context.Context, interval time.Duration) {
 	common.Logger.Infof("Starting auto-refresh with interval %v", interval)
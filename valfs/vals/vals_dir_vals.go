@@ -8,6 +8,10 @@ import (
 	"github.com/404wolf/valgo"
 )
 
+// Val town requires at least one character of code, so empty vals are stored
+// with this as their code
+const EmptyValCode = " "
+
 // BaseVal represents a val object with methods to set attributes
 type ValDirVal struct {
 	authorName     string
@@ -18,6 +22,7 @@ type ValDirVal struct {
 	code           string
 	privacy        string
 	readme         string
+	readmeSet      bool
 	version        int32
 	endpointLink   string
 	moduleLink     string
@@ -28,6 +33,17 @@ type ValDirVal struct {
 	url            string
 	likeCount      int32
 	referenceCount int32
+	remote         valDirValState // Last known state of the val on val town
+}
+
+// valDirValState holds the editable fields of a val, to diff against when
+// updating
+type valDirValState struct {
+	name    string
+	valType string
+	code    string
+	privacy string
+	readme  string
 }
 
 // ValDirValOf gets a new Val instance for a val with an id that already
@@ -49,9 +65,9 @@ func CreateValDirVal(
 	createReq.SetType(string(valType))
 	createReq.SetPrivacy(privacy)
 
-	extVal, _, err := apiClient.APIClient.ValsAPI.ValsCreate(ctx).ValsCreateRequest(*createReq).Execute()
+	extVal, resp, err := apiClient.APIClient.ValsAPI.ValsCreate(ctx).ValsCreateRequest(*createReq).Execute()
 	if err != nil {
-		return nil, err
+		return nil, common.WrapAPIError(err, resp)
 	}
 
 	return ValDirValOf(apiClient, extVal.GetId()), nil
@@ -59,51 +75,75 @@ func CreateValDirVal(
 
 // DeleteValDirVil deletes a val from the server
 func DeleteValDirVal(ctx context.Context, apiClient *common.APIClient, valId string) error {
-	_, err := apiClient.APIClient.ValsAPI.ValsDelete(ctx, valId).Execute()
-	return err
+	resp, err := apiClient.APIClient.ValsAPI.ValsDelete(ctx, valId).Execute()
+	return common.WrapAPIError(err, resp)
 }
 
-// Update updates the val information on the server
+// Update updates the val information on the server. Only the metadata that
+// differs from the last known state of the val on val town is sent, and a new
+// version is only created if the code changed.
 func (v *ValDirVal) Update(ctx context.Context) error {
 	// If the metadata changed, update the metadata
 	updateReq := valgo.NewValsUpdateRequest()
+	metadataChanged := false
 
-	if v.name != "" {
+	if v.name != "" && v.name != v.remote.name {
 		updateReq.SetName(v.name)
+		metadataChanged = true
 	}
 
-	if v.valType != "" {
+	if v.valType != "" && v.valType != v.remote.valType {
 		updateReq.SetType(v.valType)
+		metadataChanged = true
 	}
 
-	if v.privacy != "" {
+	if v.privacy != "" && v.privacy != v.remote.privacy {
 		updateReq.SetPrivacy(v.privacy)
+		metadataChanged = true
 	}
 
-	if v.readme != "" {
+	// An empty readme is only sent if it was actually loaded or set, so that
+	// vals we only know from a listing don't get their readme cleared
+	if v.readmeSet && v.readme != v.remote.readme {
 		updateReq.SetReadme(v.readme)
+		metadataChanged = true
 	}
 
-	_, err := v.apiClient.APIClient.ValsAPI.ValsUpdate(ctx, v.valId).ValsUpdateRequest(*updateReq).Execute()
-	if err != nil {
-		return err
+	codeChanged := normalizeCode(v.code) != normalizeCode(v.remote.code)
+
+	if !metadataChanged && !codeChanged {
+		common.Logger.Info("Val unchanged, skipping update", "valId", v.GetId())
+		return nil
 	}
 
-	// If the code changed, update the code seperately, because of a bug in the
-	// val town API where you cannot set the code and metadata in the same request
-	valCreateReqData := valgo.NewValsCreateRequest(v.code)
-	// Val town requires at least one character
-	if len(valCreateReqData.Code) == 0 {
-		valCreateReqData.Code = " "
+	if metadataChanged {
+		resp, err := v.apiClient.APIClient.ValsAPI.ValsUpdate(ctx, v.valId).ValsUpdateRequest(*updateReq).Execute()
+		if err != nil {
+			return common.WrapAPIError(err, resp)
+		}
+
+		v.remote.name = v.name
+		v.remote.valType = v.valType
+		v.remote.privacy = v.privacy
+		v.remote.readme = v.readme
+		common.Logger.Info("Successfully updated val metadata", "valId", v.GetId())
 	}
 
+	if !codeChanged {
+		return nil
+	}
+
+	// Update the code seperately, because of a bug in the val town API where
+	// you cannot set the code and metadata in the same request
+	valCreateReqData := valgo.NewValsCreateRequest(normalizeCode(v.code))
+
 	// Create new version
-	extVal, _, err := v.apiClient.APIClient.ValsAPI.ValsCreateVersion(ctx, v.GetId()).
+	extVal, resp, err := v.apiClient.APIClient.ValsAPI.ValsCreateVersion(ctx, v.GetId()).
 		ValsCreateRequest(*valCreateReqData).
 		Execute()
 	if err != nil {
 		common.Logger.Error("Error creating new version", "error", err)
-		return err
+		return common.WrapAPIError(err, resp)
 	}
 	v.setExtendedValProperties(extVal)
 
@@ -111,11 +151,30 @@ func (v *ValDirVal) Update(ctx context.Context) error {
 	return nil
 }
 
+// normalizeCode returns the code as val town stores it
+func normalizeCode(code string) string {
+	if len(code) == 0 {
+		return EmptyValCode
+	}
+	return code
+}
+
+// markSynced records the current state of the val as its state on val town
+func (v *ValDirVal) markSynced() {
+	v.remote = valDirValState{
+		name:    v.name,
+		valType: v.valType,
+		code:    v.code,
+		privacy: v.privacy,
+		readme:  v.readme,
+	}
+}
+
 // Load retrieves the val details from the server
 func (v *ValDirVal) Load(ctx context.Context) error {
-	val, _, err := v.apiClient.APIClient.ValsAPI.ValsGet(ctx, v.valId).Execute()
+	val, resp, err := v.apiClient.APIClient.ValsAPI.ValsGet(ctx, v.valId).Execute()
 	if err != nil {
-		return err
+		return common.WrapAPIError(err, resp)
 	}
 
 	// Load extended properties
@@ -146,6 +205,7 @@ func (v *ValDirVal) setExtendedValProperties(val *valgo.ExtendedVal) {
 	// Set readme, ensuring it's handled properly as NullableString
 	if val.Readme.IsSet() {
 		v.readme = val.GetReadme()
+		v.readmeSet = true
 	}
 
 	// Set links
@@ -165,38 +225,63 @@ func (v *ValDirVal) setExtendedValProperties(val *valgo.ExtendedVal) {
 		v.authorId = authorData.GetId()
 		v.authorName = authorData.GetUsername()
 	}
+
+	v.markSynced()
 }
 
 // ListValDirVals is a standalone function to list vals with pagination
 func ListValDirVals(ctx context.Context, apiClient *common.APIClient) ([]Val, error) {
-	meResp, _, err := apiClient.APIClient.MeAPI.MeGet(ctx).Execute()
+	meResp, resp, err := apiClient.APIClient.MeAPI.MeGet(ctx).Execute()
+	if err != nil {
+		return nil, common.WrapAPIError(err, resp)
+	}
+
+	return ListUserVals(ctx, apiClient, meResp.GetId())
+}
+
+// ListUserVals lists all the vals of a user that we can see, with pagination
+func ListUserVals(ctx context.Context, apiClient *common.APIClient, userId string) ([]Val, error) {
+	allBasicVals, err := listPaginatedVals(func(offset int32) ([]valgo.BasicVal, error) {
+		page, resp, err := apiClient.APIClient.UsersAPI.UsersVals(ctx, userId).
+			Offset(offset).
+			Limit(ApiPageLimit).
+			Execute()
+		if err != nil {
+			return nil, common.WrapAPIError(err, resp)
+		}
+		return page.Data, nil
+	})
 	if err != nil {
 		return nil, err
 	}
 
+	return valDirValsOf(apiClient, allBasicVals), nil
+}
+
+// listPaginatedVals gets every page of vals from an endpoint, by calling
+// getPage with increasing offsets until there are no more vals
+func listPaginatedVals(
+	getPage func(offset int32) ([]valgo.BasicVal, error),
+) ([]valgo.BasicVal, error) {
 	var allBasicVals []valgo.BasicVal
 	currentOffset := int32(0)
 
 	for {
-		basicVals, _, err := apiClient.APIClient.UsersAPI.UsersVals(ctx, meResp.GetId()).
-			Offset(currentOffset).
-			Limit(ApiPageLimit).
-			Execute()
-
+		basicVals, err := getPage(currentOffset)
 		if err != nil {
 			return nil, err
 		}
 
 		// If no more data, break the loop
-		if len(basicVals.Data) == 0 {
+		if len(basicVals) == 0 {
 			break
 		}
 
 		// Append this page's data to our collection
-		allBasicVals = append(allBasicVals, basicVals.Data...)
+		allBasicVals = append(allBasicVals, basicVals...)
 
 		// If we got less than the limit, we've hit the end
-		if int32(len(basicVals.Data)) < ApiPageLimit {
+		if int32(len(basicVals)) < ApiPageLimit {
 			break
 		}
 
@@ -204,18 +289,62 @@ func ListValDirVals(ctx context.Context, apiClient *common.APIClient) ([]Val, er
 		currentOffset += ApiPageLimit
 	}
 
-	// Convert each of the basic vals into Val instances
-	vals := make([]Val, 0, len(allBasicVals))
-	for _, val := range allBasicVals {
-		valDirVal := ValDirValOf(apiClient, val.GetId())
+	return allBasicVals, nil
+}
+
+// valDirValsOf converts basic vals into Val instances
+func valDirValsOf(apiClient *common.APIClient, basicVals []valgo.BasicVal) []Val {
+	vals := make([]Val, 0, len(basicVals))
+	for _, val := range basicVals {
+		valDirVal := &ValDirVal{apiClient: apiClient, valId: val.GetId()}
 		valDirVal.SetName(val.Name)
 		valDirVal.SetValType(val.Type)
 		valDirVal.SetCode(val.GetCode())
 		valDirVal.SetPrivacy(val.Privacy)
-		valDirVal.SetReadme("") // No readme in BasicVal, setting empty
+		// No readme in BasicVal, it is left unset until the val is loaded
+		valDirVal.markSynced()
+		vals = append(vals, valDirVal)
+	}
+	return vals
+}
+
+// listedVal is a val in a list of vals from an endpoint that valgo doesn't
+// cover
+type listedVal struct {
+	Id      string  `json:"id"`
+	Name    string  `json:"name"`
+	Type    string  `json:"type"`
+	Code    *string `json:"code"`
+	Privacy string  `json:"privacy"`
+	Author  *struct {
+		Username *string `json:"username"`
+	} `json:"author"`
+}
+
+// listedVals is a page of vals from an endpoint that valgo doesn't cover
+type listedVals struct {
+	Data []listedVal `json:"data"`
+}
+
+// valDirValsOfListed converts listed vals into Val instances
+func valDirValsOfListed(apiClient *common.APIClient, listed []listedVal) []Val {
+	vals := make([]Val, 0, len(listed))
+	for _, val := range listed {
+		valDirVal := &ValDirVal{apiClient: apiClient, valId: val.Id}
+		valDirVal.SetName(val.Name)
+		valDirVal.SetValType(val.Type)
+		if val.Code != nil {
+			valDirVal.SetCode(*val.Code)
+		}
+		valDirVal.SetPrivacy(val.Privacy)
+		if val.Author != nil && val.Author.Username != nil {
+			valDirVal.authorName = *val.Author.Username
+		}
+		// Lists don't have readmes, they are left unset until the val is loaded
+		valDirVal.markSynced()
 		vals = append(vals, valDirVal)
 	}
-	return vals, nil
+	return vals
 }
 
 // GetVersionsLink returns the link to the val's versions
@@ -321,4 +450,5 @@ func (v *ValDirVal) SetPrivacy(privacy string) {
 // SetReadme sets the readme of the val
 func (v *ValDirVal) SetReadme(readme string) {
 	v.readme = readme
+	v.readmeSet = true
 }
@@ -0,0 +1,244 @@
+package valfs
+
+import (
+	"context"
+	"path"
+	"strings"
+	"syscall"
+
+	common "github.com/404wolf/valfs/common"
+	"github.com/hanwen/go-fuse/v2/fs"
+	"github.com/hanwen/go-fuse/v2/fuse"
+)
+
+// ValsFolder is a folder inside of the vals dir that vals can be grouped into.
+// It only exists locally, and everything done in it is handled by the vals
+// dir that it is in.
+type ValsFolder struct {
+	fs.Inode
+
+	root *ValsDir // The vals dir that the folder is in
+}
+
+var _ = (fs.NodeRenamer)((*ValsFolder)(nil))
+var _ = (fs.NodeCreater)((*ValsFolder)(nil))
+var _ = (fs.NodeUnlinker)((*ValsFolder)(nil))
+var _ = (fs.NodeMkdirer)((*ValsFolder)(nil))
+var _ = (fs.NodeRmdirer)((*ValsFolder)(nil))
+
+// folder returns the path of the folder relative to the vals dir
+func (f *ValsFolder) folder() string {
+	return f.Path(&f.root.Inode)
+}
+
+// Create a new val in the folder on new file creation
+func (f *ValsFolder) Create(
+	ctx context.Context,
+	name string,
+	flags uint32,
+	mode uint32,
+	entryOut *fuse.EntryOut,
+) (inode *fs.Inode, fh fs.FileHandle, fuseFlags uint32, code syscall.Errno) {
+	return f.root.create(ctx, &f.Inode, f.folder(), name, flags)
+}
+
+// Unlink deletes the val of a file in the folder
+func (f *ValsFolder) Unlink(ctx context.Context, name string) syscall.Errno {
+	return f.root.unlink(ctx, &f.Inode, name)
+}
+
+// Rename a val or folder in the folder, possibly moving it to another folder
+func (f *ValsFolder) Rename(
+	ctx context.Context,
+	oldName string,
+	newParent fs.InodeEmbedder,
+	newName string,
+	flags uint32,
+) syscall.Errno {
+	return f.root.rename(ctx, &f.Inode, oldName, newParent, newName)
+}
+
+// Mkdir creates a folder inside of the folder
+func (f *ValsFolder) Mkdir(
+	ctx context.Context,
+	name string,
+	mode uint32,
+	out *fuse.EntryOut,
+) (*fs.Inode, syscall.Errno) {
+	return f.root.mkdir(ctx, &f.Inode, f.folder(), name)
+}
+
+// Rmdir removes an empty folder inside of the folder
+func (f *ValsFolder) Rmdir(ctx context.Context, name string) syscall.Errno {
+	return f.root.rmdir(&f.Inode, f.folder(), name)
+}
+
+// Mkdir creates a folder to group vals in
+func (c *ValsDir) Mkdir(
+	ctx context.Context,
+	name string,
+	mode uint32,
+	out *fuse.EntryOut,
+) (*fs.Inode, syscall.Errno) {
+	return c.mkdir(ctx, &c.Inode, "", name)
+}
+
+// Rmdir removes an empty folder
+func (c *ValsDir) Rmdir(ctx context.Context, name string) syscall.Errno {
+	return c.rmdir(&c.Inode, "", name)
+}
+
+// mkdir creates a folder called name in dir, which is the vals dir or the
+// folder with the given path
+func (c *ValsDir) mkdir(
+	ctx context.Context,
+	dir *fs.Inode,
+	folder string,
+	name string,
+) (*fs.Inode, syscall.Errno) {
+	if c.folders == nil {
+		common.Logger.Warn("Mkdir failed: folders are not enabled")
+		return nil, syscall.EPERM
+	}
+
+	if dir.GetChild(name) != nil {
+		common.Logger.Warnf("Mkdir failed: %s already exists", name)
+		return nil, syscall.EEXIST
+	}
+
+	err := c.folders.AddDir(path.Join(folder, name))
+	if err != nil {
+		common.Logger.Errorf("Error saving folder %s: %v", name, err)
+		return nil, syscall.EIO
+	}
+
+	common.Logger.Infof("Created folder %s", path.Join(folder, name))
+	return c.newFolderInode(ctx, dir), syscall.F_OK
+}
+
+// rmdir removes the empty folder called name from dir, which is the vals dir
+// or the folder with the given path
+func (c *ValsDir) rmdir(dir *fs.Inode, folder string, name string) syscall.Errno {
+	if c.folders == nil {
+		common.Logger.Warn("Rmdir failed: folders are not enabled")
+		return syscall.EPERM
+	}
+
+	child := dir.GetChild(name)
+	if child == nil {
+		return syscall.ENOENT
+	}
+	if _, ok := child.Operations().(*ValsFolder); !ok {
+		return syscall.ENOTDIR
+	}
+	if len(child.Children()) > 0 {
+		return syscall.ENOTEMPTY
+	}
+
+	err := c.folders.RemoveDir(path.Join(folder, name))
+	if err != nil {
+		common.Logger.Errorf("Error removing folder %s: %v", name, err)
+		return syscall.EIO
+	}
+
+	common.Logger.Infof("Removed folder %s", path.Join(folder, name))
+	return syscall.F_OK
+}
+
+// renameFolder moves the folder at inode to newName in newDir, which is the
+// vals dir or the folder with the path newFolder
+func (c *ValsDir) renameFolder(
+	inode *fs.Inode,
+	newDir *fs.Inode,
+	newFolder string,
+	newName string,
+) syscall.Errno {
+	if c.folders == nil {
+		common.Logger.Warn("Rename failed: folders are not enabled")
+		return syscall.EPERM
+	}
+
+	if newDir.GetChild(newName) != nil {
+		common.Logger.Warnf("Destination folder already exists: %s", newName)
+		return syscall.EEXIST
+	}
+
+	oldPath := inode.Path(&c.Inode)
+	newPath := path.Join(newFolder, newName)
+	if isInDir(newPath, oldPath) {
+		common.Logger.Warnf("Cannot move folder %s into itself", oldPath)
+		return syscall.EINVAL
+	}
+
+	err := c.folders.RenameDir(oldPath, newPath)
+	if err != nil {
+		common.Logger.Errorf("Error moving folder %s: %v", oldPath, err)
+		return syscall.EIO
+	}
+
+	common.Logger.Infof("Moved folder %s to %s", oldPath, newPath)
+	return syscall.F_OK
+}
+
+// folderInode returns the inode of a folder, creating it and its parents if
+// they don't exist yet
+func (c *ValsDir) folderInode(ctx context.Context, folder string) *fs.Inode {
+	dir := &c.Inode
+	if folder == "" {
+		return dir
+	}
+
+	for _, name := range strings.Split(folder, "/") {
+		child := dir.GetChild(name)
+		if child == nil {
+			child = c.newFolderInode(ctx, dir)
+			dir.AddChild(name, child, true)
+		}
+		dir = child
+	}
+	return dir
+}
+
+// newFolderInode creates the inode of a new folder
+func (c *ValsDir) newFolderInode(ctx context.Context, dir *fs.Inode) *fs.Inode {
+	return dir.NewPersistentInode(
+		ctx,
+		&ValsFolder{root: c},
+		fs.StableAttr{Mode: syscall.S_IFDIR},
+	)
+}
+
+// folderOf returns the folder that a val is in, or "" if it is not in one
+func (c *ValsDir) folderOf(val Val) string {
+	if c.client.Config.Layout == LayoutByType {
+		return TypeDir(val.GetValType())
+	}
+	if c.folders == nil {
+		return ""
+	}
+	return c.folders.Folder(val.GetId())
+}
+
+// filename returns the name of the file of a val
+func (c *ValsDir) filename(val Val) string {
+	return ConstructLayoutFilename(c.client.Config.Layout, val.GetName(), val.GetValType())
+}
+
+// extractFromFilename returns the name and type of the val of a file in dir,
+// which is the vals dir or one of its folders
+func (c *ValsDir) extractFromFilename(dir *fs.Inode, filename string) (string, ValType) {
+	return ExtractFromLayoutFilename(c.client.Config.Layout, dir.Path(&c.Inode), filename)
+}
+
+// folderPath returns the path of a directory relative to the vals dir, and
+// whether it is the vals dir or one of its folders at all
+func (c *ValsDir) folderPath(node fs.InodeEmbedder) (string, bool) {
+	switch dir := node.(type) {
+	case *ValsDir:
+		return "", dir == c
+	case *ValsFolder:
+		return dir.folder(), dir.root == c
+	default:
+		return "", false
+	}
+}
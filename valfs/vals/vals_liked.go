@@ -0,0 +1,57 @@
+package valfs
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	common "github.com/404wolf/valfs/common"
+	"github.com/hanwen/go-fuse/v2/fs"
+)
+
+// NewLikedDir creates a read only directory of the vals that you have liked.
+// It refreshes on the same interval as the vals directory, and when it is used
+// after that interval has passed.
+func NewLikedDir(ctx context.Context, parent *fs.Inode, client *common.Client) *ReadOnlyValsDir {
+	common.Logger.Infof("Initializing liked vals of %s", client.User.GetUsername())
+	interval := time.Duration(client.Config.AutoRefreshInterval) * time.Second
+	likedDir := NewReadOnlyValsDir(ctx, parent, client, func(ctx context.Context) ([]Val, error) {
+		return ListLikedVals(ctx, client.APIClient)
+	}, interval)
+
+	if err := likedDir.Refresh(ctx); err != nil {
+		common.Logger.Errorf("Error listing liked vals: %v", err)
+	}
+
+	if client.Config.AutoRefresh {
+		likedDir.StartAutoRefresh(ctx, interval)
+	}
+
+	return likedDir
+}
+
+// ListLikedVals lists all the vals that the authenticated user has liked
+func ListLikedVals(ctx context.Context, apiClient *common.APIClient) ([]Val, error) {
+	var allLiked []listedVal
+	for offset := 0; ; offset += ApiPageLimit {
+		params := url.Values{}
+		params.Set("offset", strconv.Itoa(offset))
+		params.Set("limit", strconv.Itoa(ApiPageLimit))
+
+		var page listedVals
+		err := apiClient.RawJSONRequest(ctx, http.MethodGet, "/v1/me/likes?"+params.Encode(), nil, &page)
+		if err != nil {
+			return nil, err
+		}
+		allLiked = append(allLiked, page.Data...)
+
+		// If we got less than the limit, we've hit the end
+		if len(page.Data) < ApiPageLimit {
+			break
+		}
+	}
+
+	return valDirValsOfListed(apiClient, allLiked), nil
+}
@@ -0,0 +1,133 @@
+package valfs
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	common "github.com/404wolf/valfs/common"
+	"github.com/hanwen/go-fuse/v2/fs"
+	"github.com/hanwen/go-fuse/v2/fuse"
+)
+
+// How long search results are cached for, and how long directories of
+// results are kept after they were last looked up
+const SearchResultsTTL = 30 * time.Second
+
+// The most vals that a search returns
+const SearchResultsLimit = ApiPageLimit
+
+// SearchDir is a directory where looking up a query, like
+// `ls search/"openai stream"`, searches all of val town and gives a read only
+// directory of the matching vals. Directories of results that weren't used for
+// a while are removed, so that every search ever made isn't kept.
+type SearchDir struct {
+	fs.Inode
+
+	client *common.Client
+
+	mutex      sync.Mutex           // Guards lookedUpAt and the results directories
+	lookedUpAt map[string]time.Time // When each query, or its results, were last used
+}
+
+var _ = (fs.NodeLookuper)((*SearchDir)(nil))
+var _ = (fs.NodeOpendirer)((*SearchDir)(nil))
+
+// NewSearchDir creates a directory for searching vals
+func NewSearchDir(ctx context.Context, parent *fs.Inode, client *common.Client) *SearchDir {
+	searchDir := &SearchDir{client: client, lookedUpAt: make(map[string]time.Time)}
+	parent.NewPersistentInode(ctx, searchDir, fs.StableAttr{Mode: syscall.S_IFDIR | 0555})
+	return searchDir
+}
+
+// Lookup creates the directory of results for a query
+func (c *SearchDir) Lookup(
+	ctx context.Context,
+	name string,
+	out *fuse.EntryOut,
+) (*fs.Inode, syscall.Errno) {
+	// Don't search for the hidden files that tools look for
+	if strings.HasPrefix(name, ".") {
+		return nil, syscall.ENOENT
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.removeExpired()
+	c.lookedUpAt[name] = time.Now()
+
+	out.Attr.Mode = syscall.S_IFDIR | 0555
+	if child := c.GetChild(name); child != nil {
+		return child, syscall.F_OK
+	}
+
+	common.Logger.Infof("Adding directory for search %q", name)
+	query := name
+	resultsDir := NewReadOnlyValsDir(ctx, &c.Inode, c.client, func(ctx context.Context) ([]Val, error) {
+		return SearchVals(ctx, c.client.APIClient, query)
+	}, SearchResultsTTL)
+	// Keep the results while they are being used, not just while the query is
+	// being looked up again
+	resultsDir.onUse = func() {
+		c.mutex.Lock()
+		defer c.mutex.Unlock()
+		if _, ok := c.lookedUpAt[query]; ok {
+			c.lookedUpAt[query] = time.Now()
+		}
+	}
+	c.AddChild(name, &resultsDir.Inode, true)
+
+	return &resultsDir.Inode, syscall.F_OK
+}
+
+// Opendir removes the directories of results that weren't used for a while before the directory is read
+func (c *SearchDir) Opendir(ctx context.Context) syscall.Errno {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.removeExpired()
+	return syscall.F_OK
+}
+
+// removeExpired removes the directories of results that weren't looked up, or
+// listed or looked up in, in the last SearchResultsTTL, along with the val files in them. The mutex must
+// be held.
+func (c *SearchDir) removeExpired() {
+	for name, lookedUpAt := range c.lookedUpAt {
+		if time.Since(lookedUpAt) < SearchResultsTTL {
+			continue
+		}
+
+		common.Logger.Infof("Removing directory for search %q", name)
+		delete(c.lookedUpAt, name)
+		if child := c.GetChild(name); child != nil {
+			// The results are persistent inodes, which are only forgotten once
+			// they are removed with everything in them
+			child.RmAllChildren()
+			c.RmChild(name)
+			go c.NotifyEntry(name)
+		}
+	}
+}
+
+// SearchVals searches all of val town for vals matching a query
+func SearchVals(ctx context.Context, apiClient *common.APIClient, query string) ([]Val, error) {
+	params := url.Values{}
+	params.Set("query", query)
+	params.Set("offset", "0")
+	params.Set("limit", strconv.Itoa(SearchResultsLimit))
+
+	var results listedVals
+	err := apiClient.RawJSONRequest(ctx, http.MethodGet, "/v1/search/vals?"+params.Encode(), nil, &results)
+	if err != nil {
+		return nil, err
+	}
+
+	return valDirValsOfListed(apiClient, results.Data), nil
+}
@@ -0,0 +1,97 @@
+package valfs
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	common "github.com/404wolf/valfs/common"
+	"github.com/hanwen/go-fuse/v2/fs"
+	"github.com/hanwen/go-fuse/v2/fuse"
+)
+
+// How long the listed vals of other users are fresh for
+const UserValsTTL = time.Minute
+
+// UsersDir is a directory with a read only directory of public vals for each
+// user. Users are resolved by their username when they are first looked up.
+type UsersDir struct {
+	fs.Inode
+
+	client *common.Client
+	mutex  sync.Mutex // Held while a user is looked up, so they are added once
+}
+
+var _ = (fs.NodeLookuper)((*UsersDir)(nil))
+
+// NewUsersDir creates a directory of the public vals of users
+func NewUsersDir(ctx context.Context, parent *fs.Inode, client *common.Client) *UsersDir {
+	usersDir := &UsersDir{client: client}
+	parent.NewPersistentInode(ctx, usersDir, fs.StableAttr{Mode: syscall.S_IFDIR | 0555})
+	return usersDir
+}
+
+// Lookup resolves a username to the directory of that user's public vals
+func (c *UsersDir) Lookup(
+	ctx context.Context,
+	name string,
+	out *fuse.EntryOut,
+) (*fs.Inode, syscall.Errno) {
+	// Usernames can't start with a dot, and shells and editors look up lots of
+	// dotfiles that would each be a request otherwise
+	if strings.HasPrefix(name, ".") {
+		return nil, syscall.ENOENT
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if child := c.GetChild(name); child != nil {
+		out.Attr.Mode = syscall.S_IFDIR | 0555
+		return child, syscall.F_OK
+	}
+
+	userId, err := lookupUserId(ctx, c.client.APIClient, name)
+	if err != nil {
+		common.Logger.Errorf("Error looking up user %s: %v", name, err)
+		return nil, common.ToErrno(err)
+	}
+
+	common.Logger.Infof("Adding directory for vals of user %s", name)
+	userDir := NewReadOnlyValsDir(ctx, &c.Inode, c.client, func(ctx context.Context) ([]Val, error) {
+		return listPublicUserVals(ctx, c.client.APIClient, userId)
+	}, UserValsTTL)
+	c.AddChild(name, &userDir.Inode, true)
+
+	out.Attr.Mode = syscall.S_IFDIR | 0555
+	return &userDir.Inode, syscall.F_OK
+}
+
+// lookupUserId resolves a username to the id of the user
+func lookupUserId(ctx context.Context, apiClient *common.APIClient, username string) (string, error) {
+	var user struct {
+		Id string `json:"id"`
+	}
+	err := apiClient.RawJSONRequest(ctx, http.MethodGet, "/v1/alias/"+url.PathEscape(username), nil, &user)
+	return user.Id, err
+}
+
+// listPublicUserVals lists the public vals of a user
+func listPublicUserVals(ctx context.Context, apiClient *common.APIClient, userId string) ([]Val, error) {
+	userVals, err := ListUserVals(ctx, apiClient, userId)
+	if err != nil {
+		return nil, err
+	}
+
+	publicVals := make([]Val, 0, len(userVals))
+	for _, val := range userVals {
+		if val.GetPrivacy() == Public {
+			publicVals = append(publicVals, val)
+		}
+	}
+	return publicVals, nil
+}
@@ -0,0 +1,115 @@
+package valfs
+
+import (
+	"context"
+	"path"
+	"syscall"
+
+	common "github.com/404wolf/valfs/common"
+	"github.com/hanwen/go-fuse/v2/fs"
+)
+
+// ValsView is a read only directory that groups vals, with a directory per
+// group holding symlinks back to the val files in the vals directory
+type ValsView struct {
+	fs.Inode
+
+	valsDir ValsContainer    // The vals directory the symlinks point into
+	groups  []string         // The names of the group directories
+	groupOf func(Val) string // Which group a val belongs to
+}
+
+// NewPrivacyView creates a view that groups vals by their privacy
+func NewPrivacyView(ctx context.Context, parent *fs.Inode, valsDir ValsContainer) *ValsView {
+	return NewValsView(ctx, parent, valsDir, Privacies, func(val Val) string {
+		return val.GetPrivacy()
+	})
+}
+
+// NewTypeView creates a view that groups vals by their type
+func NewTypeView(ctx context.Context, parent *fs.Inode, valsDir ValsContainer) *ValsView {
+	return NewValsView(ctx, parent, valsDir, TypeDirs, func(val Val) string {
+		return TypeDir(val.GetValType())
+	})
+}
+
+// NewValsView creates a view with a directory for each group, which is kept
+// in sync with the vals directory whenever it refreshes
+func NewValsView(
+	ctx context.Context,
+	parent *fs.Inode,
+	valsDir ValsContainer,
+	groups []string,
+	groupOf func(Val) string,
+) *ValsView {
+	view := &ValsView{valsDir: valsDir, groups: groups, groupOf: groupOf}
+	parent.NewPersistentInode(ctx, view, fs.StableAttr{Mode: syscall.S_IFDIR | 0555})
+
+	for _, group := range groups {
+		groupDir := view.NewPersistentInode(ctx, &fs.Inode{}, fs.StableAttr{Mode: syscall.S_IFDIR | 0555})
+		view.AddChild(group, groupDir, true)
+	}
+
+	view.Refresh(ctx)
+	valsDir.OnRefresh(view.Refresh)
+
+	return view
+}
+
+// Refresh brings the symlinks in the view in line with the val files in the
+// vals directory
+func (v *ValsView) Refresh(ctx context.Context) {
+	// The symlinks each group should have, by name, to their targets
+	wanted := make(map[string]map[string]string)
+	for _, group := range v.groups {
+		wanted[group] = make(map[string]string)
+	}
+
+	valsInode := v.valsDir.GetInode()
+	for _, valFile := range v.valsDir.ValFiles() {
+		name, dir := valFile.Parent()
+		if dir == nil {
+			continue
+		}
+
+		var group string
+		valFile.withVal(func(val Val) {
+			group = v.groupOf(val)
+		})
+
+		links, ok := wanted[group]
+		if !ok {
+			continue
+		}
+
+		// Symlinks are relative so they work wherever valfs is mounted
+		valPath := path.Join(dir.Path(valsInode), name)
+		links[name] = path.Join("..", "..", valsInode.Path(nil), valPath)
+	}
+
+	for group, links := range wanted {
+		groupDir := v.GetChild(group)
+		v.syncGroup(ctx, groupDir, links)
+	}
+}
+
+// syncGroup makes the symlinks in a group directory match links
+func (v *ValsView) syncGroup(ctx context.Context, groupDir *fs.Inode, links map[string]string) {
+	for name, child := range groupDir.Children() {
+		symlink, ok := child.Operations().(*fs.MemSymlink)
+		if target, wanted := links[name]; !wanted || !ok || string(symlink.Data) != target {
+			groupDir.RmChild(name)
+		}
+	}
+
+	for name, target := range links {
+		if groupDir.GetChild(name) != nil {
+			continue
+		}
+
+		symlink := &fs.MemSymlink{Data: []byte(target)}
+		inode := groupDir.NewPersistentInode(ctx, symlink, fs.StableAttr{Mode: syscall.S_IFLNK})
+		groupDir.AddChild(name, inode, true)
+		common.Logger.Debugf("Linked %s to %s", name, target)
+	}
+}